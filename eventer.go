@@ -8,6 +8,9 @@ type eventer struct {
 	// map of valid Event names
 	eventnames map[string]string
 
+	// mutex to protect the eventnames map
+	namesMutex sync.RWMutex
+
 	// new events get put in to the event channel
 	in eventChannel
 
@@ -52,9 +55,20 @@ type Eventer interface {
 
 // NewEventer returns a new Eventer.
 func NewEventer() Eventer {
+	return NewEventerWithBuffer(1)
+}
+
+// NewEventerWithBuffer returns a new Eventer which queues up to size published
+// events before they are delivered to subscribers. Events are never dropped:
+// once the queue is full, Publish blocks until there is room. A size below 1
+// is taken as 1.
+func NewEventerWithBuffer(size int) Eventer {
+	if size < 1 {
+		size = 1
+	}
 	evtr := &eventer{
 		eventnames: make(map[string]string),
-		in:         make(eventChannel, 1),
+		in:         make(eventChannel, size),
 		outs:       make(map[eventChannel]eventChannel),
 	}
 
@@ -75,24 +89,37 @@ func NewEventer() Eventer {
 	return evtr
 }
 
-// Events returns the map of valid Event names.
+// Events returns a copy of the map of valid Event names.
 func (e *eventer) Events() map[string]string {
-	return e.eventnames
+	e.namesMutex.RLock()
+	defer e.namesMutex.RUnlock()
+	names := make(map[string]string, len(e.eventnames))
+	for k, v := range e.eventnames {
+		names[k] = v
+	}
+	return names
 }
 
 // Event returns an Event string from map of valid Event names.
 // Mostly used to validate that an Event name is valid.
 func (e *eventer) Event(name string) string {
+	e.namesMutex.RLock()
+	defer e.namesMutex.RUnlock()
 	return e.eventnames[name]
 }
 
-// AddEvent registers a new Event name.
+// AddEvent registers a new Event name. It is safe to call while other
+// goroutines look up Event names.
 func (e *eventer) AddEvent(name string) {
+	e.namesMutex.Lock()
+	defer e.namesMutex.Unlock()
 	e.eventnames[name] = name
 }
 
 // DeleteEvent removes a previously registered Event name.
 func (e *eventer) DeleteEvent(name string) {
+	e.namesMutex.Lock()
+	defer e.namesMutex.Unlock()
 	delete(e.eventnames, name)
 }
 
@@ -1,6 +1,7 @@
 package gobot
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -24,6 +25,37 @@ func TestEventerDeleteEvent(t *testing.T) {
 	}
 }
 
+func TestEventerConcurrentAddEvent(t *testing.T) {
+	e := NewEventer()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			e.AddEvent(fmt.Sprintf("test%v", i))
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		e.Event(fmt.Sprintf("test%v", i))
+	}
+	<-done
+
+	if e.Event("test99") != "test99" {
+		t.Errorf("Could not add event to list of Event names")
+	}
+}
+
+func TestEventerWithBuffer(t *testing.T) {
+	e := NewEventerWithBuffer(10).(*eventer)
+	if cap(e.in) != 10 {
+		t.Errorf("Event buffer has size %v, expected 10", cap(e.in))
+	}
+
+	e = NewEventerWithBuffer(-1).(*eventer)
+	if cap(e.in) != 1 {
+		t.Errorf("Event buffer has size %v, expected 1", cap(e.in))
+	}
+}
+
 func TestEventerOn(t *testing.T) {
 	e := NewEventer()
 	e.AddEvent("test")
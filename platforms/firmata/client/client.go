@@ -3,10 +3,13 @@
 package client // import "gobot.io/x/gobot/platforms/firmata/client"
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"gobot.io/x/gobot"
@@ -14,11 +17,23 @@ import (
 
 // Pin Modes
 const (
-	Input  = 0x00
-	Output = 0x01
-	Analog = 0x02
-	Pwm    = 0x03
-	Servo  = 0x04
+	Input   = 0x00
+	Output  = 0x01
+	Analog  = 0x02
+	Pwm     = 0x03
+	Servo   = 0x04
+	Shift   = 0x05
+	I2c     = 0x06
+	OneWire = 0x07
+	Stepper = 0x08
+	Encoder = 0x09
+	Serial  = 0x0A
+	// InputPullup is an input with the internal pull-up resistor enabled.
+	InputPullup = 0x0B
+	Spi         = 0x0C
+	Sonar       = 0x0D
+	Tone        = 0x0E
+	Dht         = 0x0F
 )
 
 // Sysex Codes
@@ -52,22 +67,97 @@ const (
 	I2CModeContinuousRead    byte = 0x02
 	I2CModeStopReading       byte = 0x03
 	ServoConfig              byte = 0x70
+	SerialMessage            byte = 0x60
+	SerialModeConfig         byte = 0x10
+	SerialModeWrite          byte = 0x20
+	SerialModeRead           byte = 0x30
+	SerialModeReply          byte = 0x40
+	SerialReadContinuous     byte = 0x00
+	SerialStopReading        byte = 0x01
 )
 
 // Errors
 var (
-	ErrConnected = errors.New("client is already connected")
+	ErrConnected    = errors.New("client is already connected")
+	ErrResync       = errors.New("discarded corrupted data to resynchronize with the board")
+	ErrSysexOverrun = errors.New("discarded a sysex message longer than MaxSysexSize")
+	ErrUnknownPin   = errors.New("pin is not in the capability response of the board")
 )
 
+// MaxSysexSize is the largest sysex message the Client buffers, including its
+// start and end bytes. Longer messages are discarded with ErrSysexOverrun
+// rather than growing the buffer without limit.
+const MaxSysexSize = 4096
+
+// staleTimeout is how long Connect reads stale input before the handshake
+// once no more arrives.
+const staleTimeout = 10 * time.Millisecond
+
+// readDeadliner is implemented by connections whose reads can be given a
+// deadline, such as network connections.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// flusher is implemented by connections which can discard the input they
+// received but not yet read, such as serial ports.
+type flusher interface {
+	Flush() error
+}
+
+// Logger is the interface the Client logs through. It is small enough to be
+// implemented on top of any logging package.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+// PinMismatchError reports that the capability and analog mapping responses
+// of the board disagree about the number of pins, which is a firmware bug.
+// The capability response is trusted: analog mapping entries past its pins
+// are ignored, and pins without an entry have no analog channel.
+type PinMismatchError struct {
+	Capabilities  int
+	AnalogMapping int
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("capability response reports %v pins but analog mapping response reports %v",
+		e.Capabilities, e.AnalogMapping)
+}
+
 // Client represents a client connection to a firmata board
 type Client struct {
 	pins             []Pin
 	FirmwareName     string
+	firmwareMajor    int
+	firmwareMinor    int
+	firmwareReport   []byte
 	ProtocolVersion  string
 	connected        bool
 	connection       io.ReadWriteCloser
+	closed           bool
+	reader           io.Reader
+	readChunkSize    int
+	writeChunkSize   int
+	fatalTimeouts    bool
+	batch            []byte
+	batches          int
+	unread           []byte
 	analogPins       []int
+	pinMismatch      error
 	initTimeInterval time.Duration
+	logger           Logger
+	pinMutex         sync.Mutex
+	writeMutex       sync.Mutex
+	closeMutex       sync.Mutex
 	gobot.Eventer
 }
 
@@ -78,6 +168,24 @@ type Pin struct {
 	Value          int
 	State          int
 	AnalogChannel  int
+	// AnalogResolution is the resolution in bits of the analog readings of
+	// the pin, or 0 if it has no analog mode.
+	AnalogResolution int
+	// Resolutions holds the resolution in bits the capability response
+	// reports for each of SupportedModes, at the same index.
+	Resolutions []int
+	// Updated is the time the last analog or digital report for the pin was
+	// received, or the zero time if none has been.
+	Updated time.Time
+}
+
+func supportsMode(pin Pin, mode int) bool {
+	for _, m := range pin.SupportedModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
 }
 
 // I2cReply represents the response from an I2cReply message
@@ -87,6 +195,12 @@ type I2cReply struct {
 	Data     []byte
 }
 
+// SerialReply represents the data read from a serial port of the board
+type SerialReply struct {
+	Port int
+	Data []byte
+}
+
 // New returns a new Client
 func New() *Client {
 	c := &Client{
@@ -96,6 +210,7 @@ func New() *Client {
 		pins:            []Pin{},
 		analogPins:      []int{},
 		connected:       false,
+		logger:          nopLogger{},
 		Eventer:         gobot.NewEventer(),
 	}
 
@@ -105,7 +220,11 @@ func New() *Client {
 		"AnalogMappingQuery",
 		"ProtocolVersion",
 		"I2cReply",
+		"SerialReply",
 		"StringData",
+		"SysexResponse",
+		"RawSysex",
+		"Disconnect",
 		"Error",
 	} {
 		c.AddEvent(s)
@@ -114,9 +233,49 @@ func New() *Client {
 	return c
 }
 
-// Disconnect disconnects the Client
+// SetLogger sets the Logger used by the Client. A nil logger disables
+// logging, which is the default.
+func (b *Client) SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	b.logger = l
+}
+
+// SetReadChunkSize makes the Client read from the connection in chunks of up
+// to size bytes through a buffer, taking whatever the connection has available
+// with each read instead of one message part at a time. A size of zero, the
+// default, reads without a buffer. It takes effect on the next Connect.
+func (b *Client) SetReadChunkSize(size int) {
+	b.readChunkSize = size
+}
+
+// SetWriteChunkSize limits each write to the connection to size bytes, so
+// that longer messages are written in parts, for links which lose larger
+// writes. A size of zero, the default, writes each message at once.
+func (b *Client) SetWriteChunkSize(size int) {
+	b.writeChunkSize = size
+}
+
+// SetFatalReadTimeouts makes a read timeout of the connection, such as the
+// read timeout of a serial port, count as the loss of the connection, as the
+// end of the connection does. By default a read timeout only means that the
+// board had nothing to send, and reading goes on where it stopped.
+func (b *Client) SetFatalReadTimeouts(fatal bool) {
+	b.fatalTimeouts = fatal
+}
+
+// Disconnect disconnects the Client and closes its connection. Calling it
+// again, or before Connect, does nothing and returns nil.
 func (b *Client) Disconnect() (err error) {
 	b.connected = false
+
+	b.closeMutex.Lock()
+	defer b.closeMutex.Unlock()
+	if b.connection == nil || b.closed {
+		return nil
+	}
+	b.closed = true
 	return b.connection.Close()
 }
 
@@ -125,20 +284,88 @@ func (b *Client) Connected() bool {
 	return b.connected
 }
 
-// Pins returns all available pins
+// Firmware returns the name and version reported by the firmware on the board.
+func (b *Client) Firmware() (name string, major int, minor int) {
+	return b.FirmwareName, b.firmwareMajor, b.firmwareMinor
+}
+
+// RawFirmwareReport returns a copy of the last REPORT_FIRMWARE message of the
+// board as received, from the START_SYSEX byte to the END_SYSEX byte, or nil
+// if none has been received. Custom firmware may pack data after the name
+// which Firmware does not decode.
+func (b *Client) RawFirmwareReport() []byte {
+	if b.firmwareReport == nil {
+		return nil
+	}
+	return append([]byte{}, b.firmwareReport...)
+}
+
+// Pin returns a copy of the state of pin, without its supported modes and
+// their resolutions, and whether the pin exists. Unlike Pins it does not copy
+// every pin, so it suits frequent lookups of a mode or value.
+func (b *Client) Pin(pin int) (Pin, bool) {
+	b.pinMutex.Lock()
+	defer b.pinMutex.Unlock()
+
+	if pin < 0 || pin >= len(b.pins) {
+		return Pin{}, false
+	}
+	p := b.pins[pin]
+	p.SupportedModes = nil
+	p.Resolutions = nil
+	return p, true
+}
+
+// hasPin reports whether the capability response of the board lists pin.
+func (b *Client) hasPin(pin int) bool {
+	b.pinMutex.Lock()
+	defer b.pinMutex.Unlock()
+	return pin >= 0 && pin < len(b.pins)
+}
+
+// PinMismatch returns a *PinMismatchError if the last capability and analog
+// mapping responses of the board disagreed about the number of pins, or nil.
+func (b *Client) PinMismatch() error {
+	b.pinMutex.Lock()
+	defer b.pinMutex.Unlock()
+	return b.pinMismatch
+}
+
+// Pins returns all available pins. The returned pins are copies, so changing
+// them does not affect the state of the Client.
 func (b *Client) Pins() []Pin {
-	return b.pins
+	b.pinMutex.Lock()
+	defer b.pinMutex.Unlock()
+
+	pins := make([]Pin, len(b.pins))
+	for i, pin := range b.pins {
+		pins[i] = pin
+		pins[i].SupportedModes = append([]int(nil), pin.SupportedModes...)
+		pins[i].Resolutions = append([]int(nil), pin.Resolutions...)
+	}
+	return pins
 }
 
-// Connect connects to the Client given conn. It first resets the firmata board
-// then continuously polls the firmata board for new information when it's
-// available.
+// Connect connects to the Client given conn. It first discards any input left
+// over from before the connection, resets the firmata board and then
+// continuously polls the firmata board for new information when it's
+// available. When the connection reaches EOF, or a read times out with
+// SetFatalReadTimeouts, polling stops, the Client is marked as disconnected
+// and the "Disconnect" event is published.
 func (b *Client) Connect(conn io.ReadWriteCloser) (err error) {
 	if b.connected {
 		return ErrConnected
 	}
 
+	b.closeMutex.Lock()
 	b.connection = conn
+	b.closed = false
+	b.closeMutex.Unlock()
+	b.discardStale()
+	b.reader = nil
+	if b.readChunkSize > 0 {
+		b.reader = bufio.NewReaderSize(conn, b.readChunkSize)
+	}
 	b.Reset()
 
 	initFunc := b.ProtocolVersionQuery
@@ -160,6 +387,7 @@ func (b *Client) Connect(conn io.ReadWriteCloser) (err error) {
 		b.ReportDigital(0, 1)
 		b.ReportDigital(1, 1)
 		b.connected = true
+		b.logger.Infof("firmata: connected to %v, protocol %v", b.FirmwareName, b.ProtocolVersion)
 	})
 
 	for {
@@ -170,49 +398,122 @@ func (b *Client) Connect(conn io.ReadWriteCloser) (err error) {
 			return err
 		}
 		if b.connected {
-			go func() {
-				for {
-					if !b.connected {
-						break
-					}
-
-					if err := b.process(); err != nil {
-						b.Publish(b.Event("Error"), err)
-					}
-				}
-			}()
+			go b.poll()
 			break
 		}
 	}
 	return
 }
 
+// poll processes messages from the board until the Client is disconnected or
+// the connection is lost.
+func (b *Client) poll() {
+	for b.connected {
+		if err := b.process(); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				b.connected = false
+				b.logger.Infof("firmata: connection closed by the board")
+				b.Publish(b.Event("Disconnect"), err)
+				return
+			}
+			if isTimeout(err) {
+				b.connected = false
+				b.logger.Infof("firmata: board stopped sending: %v", err)
+				b.Publish(b.Event("Disconnect"), err)
+				return
+			}
+			b.logger.Errorf("firmata: %v", err)
+			b.Publish(b.Event("Error"), err)
+		}
+	}
+}
+
 // Reset sends the SystemReset sysex code.
 func (b *Client) Reset() error {
 	return b.write([]byte{SystemReset})
 }
 
-// SetPinMode sets the pin to mode.
+// SetPinMode sets the pin to mode. The cached mode of the pin is only
+// changed once the message has been written.
 func (b *Client) SetPinMode(pin int, mode int) error {
+	if !b.hasPin(pin) {
+		return ErrUnknownPin
+	}
+	if err := b.write([]byte{PinMode, byte(pin), byte(mode)}); err != nil {
+		return err
+	}
+	b.pinMutex.Lock()
 	b.pins[byte(pin)].Mode = mode
-	return b.write([]byte{PinMode, byte(pin), byte(mode)})
+	b.pinMutex.Unlock()
+	return nil
 }
 
 // DigitalWrite writes value to pin.
 func (b *Client) DigitalWrite(pin int, value int) error {
 	port := byte(math.Floor(float64(pin) / 8))
-	portValue := byte(0)
 
+	b.pinMutex.Lock()
+	defer b.pinMutex.Unlock()
+	if pin < 0 || pin >= len(b.pins) {
+		return ErrUnknownPin
+	}
 	b.pins[pin].Value = value
 
+	return b.writePort(port)
+}
+
+// DigitalWriteMulti writes the value for each pin in values. Pins sharing a
+// port are written with a single message, leaving the other pins of the port
+// unchanged.
+func (b *Client) DigitalWriteMulti(values map[int]int) error {
+	b.pinMutex.Lock()
+	defer b.pinMutex.Unlock()
+
+	for pin := range values {
+		if pin < 0 || pin >= len(b.pins) {
+			return ErrUnknownPin
+		}
+	}
+	ports := []int{}
+	for pin, value := range values {
+		b.pins[pin].Value = value
+		if port := pin / 8; !containsInt(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	sort.Ints(ports)
+
+	for _, port := range ports {
+		if err := b.writePort(byte(port)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePort sends the cached values of the pins of port to the board. It must
+// be called with pinMutex held, so that concurrent writes to the same port
+// reach the board in the order the values were cached.
+func (b *Client) writePort(port byte) error {
+	portValue := byte(0)
 	for i := byte(0); i < 8; i++ {
-		if b.pins[8*port+i].Value != 0 {
+		pin := int(8*port + i)
+		if pin < len(b.pins) && b.pins[pin].Value != 0 {
 			portValue = portValue | (1 << i)
 		}
 	}
 	return b.write([]byte{DigitalMessage | port, portValue & 0x7F, (portValue >> 7) & 0x7F})
 }
 
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // ServoConfig sets the min and max pulse width for servo PWM range
 func (b *Client) ServoConfig(pin int, max int, min int) error {
 	ret := []byte{
@@ -228,7 +529,13 @@ func (b *Client) ServoConfig(pin int, max int, min int) error {
 
 // AnalogWrite writes value to pin.
 func (b *Client) AnalogWrite(pin int, value int) error {
+	b.pinMutex.Lock()
+	if pin < 0 || pin >= len(b.pins) {
+		b.pinMutex.Unlock()
+		return ErrUnknownPin
+	}
 	b.pins[pin].Value = value
+	b.pinMutex.Unlock()
 	return b.write([]byte{AnalogMessage | byte(pin), byte(value & 0x7F), byte((value >> 7) & 0x7F)})
 }
 
@@ -269,15 +576,61 @@ func (b *Client) ReportAnalog(pin int, state int) error {
 	return b.togglePinReporting(pin, state, ReportAnalog)
 }
 
+// I2cTenBit is combined with an address, as in address|I2cTenBit, to address
+// an I2C device using 10-bit addressing.
+const I2cTenBit = 1 << 15
+
+// I2cRestart is combined with an address, as in address|I2cRestart, to end
+// the request with a repeated start instead of a stop condition, so that the
+// bus is kept for the next request. It sets the auto restart bit of the
+// request, which firmware without support for it ignores.
+const I2cRestart = 1 << 16
+
+// i2cRequest returns the bytes of an I2C request for address in mode.
+func i2cRequest(address int, mode byte) []byte {
+	flags := mode << 3
+	if address&I2cTenBit != 0 {
+		flags |= 1<<5 | byte(address>>7)&0x07
+	}
+	if address&I2cRestart != 0 {
+		flags |= 1 << 6
+	}
+	return []byte{I2CRequest, byte(address) & 0x7F, flags}
+}
+
 // I2cRead reads numBytes from address once.
 func (b *Client) I2cRead(address int, numBytes int) error {
-	return b.writeSysex([]byte{I2CRequest, byte(address), (I2CModeRead << 3),
-		byte(numBytes) & 0x7F, (byte(numBytes) >> 7) & 0x7F})
+	return b.writeSysex(append(i2cRequest(address, I2CModeRead),
+		byte(numBytes)&0x7F, (byte(numBytes)>>7)&0x7F))
+}
+
+// I2cReadRegister reads numBytes from register of address once.
+func (b *Client) I2cReadRegister(address int, register int, numBytes int) error {
+	return b.writeSysex(append(i2cRequest(address, I2CModeRead),
+		byte(register)&0x7F, byte(register>>7)&0x7F,
+		byte(numBytes)&0x7F, byte(numBytes>>7)&0x7F))
+}
+
+// I2cReadContinuous makes the board read numBytes from register of address
+// repeatedly, at the sampling interval, and send each reading as an I2C reply
+// until I2cStopReading is called for address.
+func (b *Client) I2cReadContinuous(address int, register int, numBytes int) error {
+	return b.writeSysex(append(i2cRequest(address, I2CModeContinuousRead),
+		byte(register)&0x7F, byte(register>>7)&0x7F,
+		byte(numBytes)&0x7F, byte(numBytes>>7)&0x7F))
+}
+
+// I2cStopReading stops one continuous read of address. Firmata identifies the
+// read by address alone: with several continuous reads of address, the board
+// stops the first one it was asked for, and with a single continuous read in
+// progress, it stops that one whatever its address.
+func (b *Client) I2cStopReading(address int) error {
+	return b.writeSysex(i2cRequest(address, I2CModeStopReading))
 }
 
 // I2cWrite writes data to address.
 func (b *Client) I2cWrite(address int, data []byte) error {
-	ret := []byte{I2CRequest, byte(address), (I2CModeWrite << 3)}
+	ret := i2cRequest(address, I2CModeWrite)
 	for _, val := range data {
 		ret = append(ret, byte(val&0x7F))
 		ret = append(ret, byte((val>>7)&0x7F))
@@ -291,6 +644,51 @@ func (b *Client) I2cConfig(delay int) error {
 	return b.writeSysex([]byte{I2CConfig, byte(delay & 0xFF), byte((delay >> 8) & 0xFF)})
 }
 
+// SerialConfig configures the serial port of the board with the given baud
+// rate. Ports 0x00-0x07 are hardware serial ports, and ports 0x08-0x0F are
+// software serial ports, which also need their rxPin and txPin. Negative pins
+// are left out of the message, as hardware ports have fixed pins.
+func (b *Client) SerialConfig(port int, baud int, rxPin int, txPin int) error {
+	ret := []byte{
+		SerialMessage,
+		SerialModeConfig | byte(port&0x0F),
+		byte(baud & 0x7F),
+		byte((baud >> 7) & 0x7F),
+		byte((baud >> 14) & 0x7F),
+	}
+	if rxPin >= 0 && txPin >= 0 {
+		ret = append(ret, byte(rxPin), byte(txPin))
+	}
+	return b.writeSysex(ret)
+}
+
+// SerialWrite writes data to the serial port of the board.
+func (b *Client) SerialWrite(port int, data []byte) error {
+	ret := []byte{SerialMessage, SerialModeWrite | byte(port&0x0F)}
+	for _, val := range data {
+		ret = append(ret, byte(val&0x7F))
+		ret = append(ret, byte((val>>7)&0x7F))
+	}
+	return b.writeSysex(ret)
+}
+
+// SerialRead starts continuously reading the serial port of the board, which
+// sends what it reads as "SerialReply" events. A maxBytes above 0 limits the
+// number of bytes sent at once.
+func (b *Client) SerialRead(port int, maxBytes int) error {
+	ret := []byte{SerialMessage, SerialModeRead | byte(port&0x0F), SerialReadContinuous}
+	if maxBytes > 0 {
+		ret = append(ret, byte(maxBytes&0x7F), byte((maxBytes>>7)&0x7F))
+	}
+	return b.writeSysex(ret)
+}
+
+// WriteSysex writes an arbitrary Sysex command to the microcontroller. The
+// StartSysex and EndSysex bytes are added by WriteSysex.
+func (b *Client) WriteSysex(data []byte) (err error) {
+	return b.writeSysex(data)
+}
+
 func (b *Client) togglePinReporting(pin int, state int, mode byte) error {
 	if state != 0 {
 		state = 1
@@ -310,23 +708,233 @@ func (b *Client) writeSysex(data []byte) (err error) {
 	return b.write(append([]byte{StartSysex}, append(data, EndSysex)...))
 }
 
+// WriteRaw writes data to the connection as it is, without framing it as a
+// message. It is not interleaved with the other writes of the Client, but
+// data which is not a complete message desynchronizes the firmware.
+func (b *Client) WriteRaw(data []byte) (int, error) {
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+	if b.batches > 0 {
+		b.batch = append(b.batch, data...)
+		return len(data), nil
+	}
+	return b.connection.Write(data)
+}
+
+// BeginBatch makes the Client hold the messages it writes, from any
+// goroutine, until the matching EndBatch writes them all to the connection at
+// once, saving a write call per message. Batches may be nested; the messages
+// are written when the outermost one ends.
+func (b *Client) BeginBatch() {
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+	b.batches++
+}
+
+// EndBatch ends a batch started with BeginBatch, writing the messages held
+// in the order they were made if it was the outermost batch.
+func (b *Client) EndBatch() error {
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+	if b.batches == 0 {
+		return nil
+	}
+	b.batches--
+	if b.batches > 0 || len(b.batch) == 0 {
+		return nil
+	}
+	data := b.batch
+	b.batch = nil
+	return b.writeConn(data)
+}
+
 func (b *Client) write(data []byte) (err error) {
+	b.writeMutex.Lock()
+	defer b.writeMutex.Unlock()
+	if b.batches > 0 {
+		b.batch = append(b.batch, data...)
+		return nil
+	}
+	return b.writeConn(data)
+}
+
+// writeConn writes data to the connection in chunks of writeChunkSize. It
+// must be called with writeMutex held.
+func (b *Client) writeConn(data []byte) (err error) {
+	size := b.writeChunkSize
+	for size > 0 && len(data) > size {
+		if _, err = b.connection.Write(data[:size]); err != nil {
+			return
+		}
+		data = data[size:]
+	}
 	_, err = b.connection.Write(data[:])
 	return
 }
 
 func (b *Client) read(n int) (buf []byte, err error) {
 	buf = make([]byte, n)
-	_, err = io.ReadFull(b.connection, buf)
-	return
+	i := copy(buf, b.unread)
+	b.unread = b.unread[i:]
+	r := b.reader
+	if r == nil {
+		r = b.connection
+	}
+
+	start := i
+	for i < n {
+		m, err := r.Read(buf[i:])
+		i += m
+		switch {
+		case i >= n || err == nil:
+		case isTimeout(err) && !b.fatalTimeouts && !b.isClosed():
+		case err == io.EOF && i > start:
+			return buf, io.ErrUnexpectedEOF
+		default:
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// timeoutError is implemented by errors which tell whether they are a
+// timeout, such as net.Error.
+type timeoutError interface {
+	Timeout() bool
+}
+
+// isTimeout reports whether err is a read timeout, such as one of a read
+// deadline or of the read timeout of a serial port.
+func isTimeout(err error) bool {
+	t, ok := err.(timeoutError)
+	return ok && t.Timeout()
+}
+
+// isClosed reports whether Disconnect closed the connection.
+func (b *Client) isClosed() bool {
+	b.closeMutex.Lock()
+	defer b.closeMutex.Unlock()
+	return b.closed
+}
+
+// isMessageStart reports whether val starts a message the board may send.
+func isMessageStart(val byte) bool {
+	return val == ProtocolVersion || val == StartSysex ||
+		(AnalogMessageRangeStart <= val && AnalogMessageRangeEnd >= val) ||
+		(DigitalMessageRangeStart <= val && DigitalMessageRangeEnd >= val)
+}
+
+// discardStale drops the input buffered from an earlier connection and the
+// reports the board queued before the handshake, so that they are not parsed
+// as answers to it. Connections such as serial ports are flushed, and those
+// which support read deadlines are read until no more input arrives.
+func (b *Client) discardStale() {
+	b.unread = nil
+	if f, ok := b.connection.(flusher); ok {
+		f.Flush()
+	}
+	d, ok := b.connection.(readDeadliner)
+	if !ok {
+		return
+	}
+
+	buf := make([]byte, 256)
+	discarded := 0
+	for {
+		if err := d.SetReadDeadline(time.Now().Add(staleTimeout)); err != nil {
+			return
+		}
+		n, err := b.connection.Read(buf)
+		discarded += n
+		if err != nil || n == 0 {
+			break
+		}
+	}
+	d.SetReadDeadline(time.Time{})
+	if discarded > 0 {
+		b.logger.Debugf("firmata: discarded %v stale bytes", discarded)
+	}
+}
+
+// discardSysex skips the rest of a sysex message, up to its end or the start
+// of the next message.
+func (b *Client) discardSysex() error {
+	for {
+		buf, err := b.read(1)
+		if err != nil {
+			return err
+		}
+		if buf[0] == EndSysex {
+			return nil
+		}
+		if buf[0]&0x80 != 0 {
+			b.unread = append(b.unread, buf[0])
+			return nil
+		}
+	}
+}
+
+// resync reports that corrupted data was discarded.
+func (b *Client) resync() {
+	b.logger.Debugf("firmata: %v", ErrResync)
+	b.Publish(b.Event("Error"), ErrResync)
+}
+
+// readMessageStart reads until the start of a message, skipping any bytes
+// which cannot start one. Skipped bytes are reported with ErrResync.
+func (b *Client) readMessageStart() (messageType byte, err error) {
+	skipped := false
+	for {
+		buf, err := b.read(1)
+		if err != nil {
+			return 0, err
+		}
+		if isMessageStart(buf[0]) {
+			if skipped {
+				b.resync()
+			}
+			return buf[0], nil
+		}
+		skipped = true
+	}
+}
+
+// readData reads n data bytes. If a byte with the high bit set arrives first,
+// the message was cut short: the byte is kept as the start of the next
+// message and ErrResync is returned.
+func (b *Client) readData(n int) (buf []byte, err error) {
+	buf = make([]byte, 0, n)
+	for len(buf) < n {
+		val, err := b.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if val[0]&0x80 != 0 {
+			b.unread = append(b.unread, val[0])
+			return nil, ErrResync
+		}
+		buf = append(buf, val[0])
+	}
+	return buf, nil
 }
 
 func (b *Client) process() (err error) {
-	buf, err := b.read(3)
+	messageType, err := b.readMessageStart()
 	if err != nil {
 		return err
 	}
-	messageType := buf[0]
+
+	var buf []byte
+	if messageType != StartSysex {
+		if buf, err = b.readData(2); err == ErrResync {
+			b.resync()
+			return nil
+		} else if err != nil {
+			return err
+		}
+		buf = append([]byte{messageType}, buf...)
+	}
+
 	switch {
 	case ProtocolVersion == messageType:
 		b.ProtocolVersion = fmt.Sprintf("%v.%v", buf[1], buf[2])
@@ -338,11 +946,16 @@ func (b *Client) process() (err error) {
 		value := uint(buf[1]) | uint(buf[2])<<7
 		pin := int((messageType & 0x0F))
 
-		if len(b.analogPins) > pin {
-			if len(b.pins) > b.analogPins[pin] {
-				b.pins[b.analogPins[pin]].Value = int(value)
-				b.Publish(b.Event(fmt.Sprintf("AnalogRead%v", pin)), b.pins[b.analogPins[pin]].Value)
-			}
+		b.pinMutex.Lock()
+		updated := len(b.analogPins) > pin && len(b.pins) > b.analogPins[pin]
+		if updated {
+			b.pins[b.analogPins[pin]].Value = int(value)
+			b.pins[b.analogPins[pin]].Updated = time.Now()
+		}
+		b.pinMutex.Unlock()
+
+		if updated {
+			b.Publish(b.Event(fmt.Sprintf("AnalogRead%v", pin)), int(value))
 		}
 	case DigitalMessageRangeStart <= messageType &&
 		DigitalMessageRangeEnd >= messageType:
@@ -350,74 +963,145 @@ func (b *Client) process() (err error) {
 		port := messageType & 0x0F
 		portValue := buf[1] | (buf[2] << 7)
 
+		reads := [][2]int{}
+		now := time.Now()
+		b.pinMutex.Lock()
 		for i := 0; i < 8; i++ {
 			pinNumber := int((8*byte(port) + byte(i)))
 			if len(b.pins) > pinNumber {
-				if b.pins[pinNumber].Mode == Input {
+				if b.pins[pinNumber].Mode == Input || b.pins[pinNumber].Mode == InputPullup {
 					b.pins[pinNumber].Value = int((portValue >> (byte(i) & 0x07)) & 0x01)
-					b.Publish(b.Event(fmt.Sprintf("DigitalRead%v", pinNumber)), b.pins[pinNumber].Value)
+					b.pins[pinNumber].Updated = now
+					reads = append(reads, [2]int{pinNumber, b.pins[pinNumber].Value})
 				}
 			}
 		}
+		b.pinMutex.Unlock()
+
+		for _, read := range reads {
+			b.Publish(b.Event(fmt.Sprintf("DigitalRead%v", read[0])), read[1])
+		}
 	case StartSysex == messageType:
-		currentBuffer := buf
+		currentBuffer := []byte{StartSysex}
 		for {
 			buf, err = b.read(1)
 			if err != nil {
 				return err
 			}
+			if buf[0]&0x80 != 0 && buf[0] != EndSysex {
+				// a new message started before the sysex was terminated
+				b.unread = append(b.unread, buf[0])
+				b.resync()
+				return nil
+			}
 			currentBuffer = append(currentBuffer, buf[0])
 			if buf[0] == EndSysex {
 				break
 			}
+			if len(currentBuffer) >= MaxSysexSize {
+				b.logger.Debugf("firmata: %v", ErrSysexOverrun)
+				b.Publish(b.Event("Error"), ErrSysexOverrun)
+				return b.discardSysex()
+			}
+		}
+		if len(currentBuffer) < 3 {
+			return nil
 		}
+		raw := make([]byte, len(currentBuffer))
+		copy(raw, currentBuffer)
+		b.Publish(b.Event("RawSysex"), raw)
+
 		command := currentBuffer[1]
 		switch command {
 		case CapabilityResponse:
-			b.pins = []Pin{}
+			pins := []Pin{}
 			supportedModes := 0
+			resolutions := map[int]int{}
+			mode := byte(0)
 			n := 0
 
-			for _, val := range currentBuffer[2:(len(currentBuffer) - 5)] {
+			for _, val := range currentBuffer[2 : len(currentBuffer)-1] {
 				if val == 127 {
 					modes := []int{}
-					for _, mode := range []int{Input, Output, Analog, Pwm, Servo} {
+					bits := []int{}
+					for mode := Input; mode <= Dht; mode++ {
 						if (supportedModes & (1 << byte(mode))) != 0 {
 							modes = append(modes, mode)
+							bits = append(bits, resolutions[mode])
 						}
 					}
 
-					b.pins = append(b.pins, Pin{SupportedModes: modes, Mode: Output})
-					b.AddEvent(fmt.Sprintf("DigitalRead%v", len(b.pins)-1))
-					b.AddEvent(fmt.Sprintf("PinState%v", len(b.pins)-1))
+					pins = append(pins, Pin{
+						SupportedModes:   modes,
+						Resolutions:      bits,
+						Mode:             Output,
+						AnalogResolution: resolutions[Analog],
+					})
+					b.AddEvent(fmt.Sprintf("DigitalRead%v", len(pins)-1))
+					b.AddEvent(fmt.Sprintf("PinState%v", len(pins)-1))
 					supportedModes = 0
+					resolutions = map[int]int{}
 					n = 0
 					continue
 				}
 
 				if n == 0 {
+					mode = val
 					supportedModes = supportedModes | (1 << val)
+				} else {
+					resolutions[int(mode)] = int(val)
 				}
 				n ^= 1
 			}
+			b.pinMutex.Lock()
+			if b.connected {
+				// a refresh keeps the state of the pins which are still there
+				for i := range pins {
+					if i < len(b.pins) && supportsMode(pins[i], b.pins[i].Mode) {
+						pins[i].Mode = b.pins[i].Mode
+						pins[i].Value = b.pins[i].Value
+						pins[i].State = b.pins[i].State
+						pins[i].Updated = b.pins[i].Updated
+					}
+				}
+			}
+			b.pins = pins
+			b.pinMutex.Unlock()
 			b.Publish(b.Event("CapabilityQuery"), nil)
 		case AnalogMappingResponse:
-			pinIndex := 0
-			b.analogPins = []int{}
-
-			for _, val := range currentBuffer[2 : len(b.pins)-1] {
+			mapping := currentBuffer[2 : len(currentBuffer)-1]
 
-				b.pins[pinIndex].AnalogChannel = int(val)
+			b.pinMutex.Lock()
+			b.pinMismatch = nil
+			if len(mapping) != len(b.pins) {
+				b.pinMismatch = &PinMismatchError{Capabilities: len(b.pins), AnalogMapping: len(mapping)}
+			}
+			b.analogPins = []int{}
+			for pinIndex := range b.pins {
+				channel := 127
+				if pinIndex < len(mapping) {
+					channel = int(mapping[pinIndex])
+				}
+				b.pins[pinIndex].AnalogChannel = channel
 
-				if val != 127 {
+				if channel != 127 {
 					b.analogPins = append(b.analogPins, pinIndex)
 				}
-				b.AddEvent(fmt.Sprintf("AnalogRead%v", pinIndex))
-				pinIndex++
+			}
+			pinCount := len(b.pins)
+			mismatch := b.pinMismatch
+			b.pinMutex.Unlock()
+
+			if mismatch != nil {
+				b.logger.Debugf("firmata: %v", mismatch)
+			}
+			for i := 0; i < pinCount; i++ {
+				b.AddEvent(fmt.Sprintf("AnalogRead%v", i))
 			}
 			b.Publish(b.Event("AnalogMappingQuery"), nil)
 		case PinStateResponse:
 			pin := currentBuffer[2]
+			b.pinMutex.Lock()
 			b.pins[pin].Mode = int(currentBuffer[3])
 			b.pins[pin].State = int(currentBuffer[4])
 
@@ -427,12 +1111,14 @@ func (b *Client) process() (err error) {
 			if len(currentBuffer) > 7 {
 				b.pins[pin].State = int(uint(b.pins[pin].State) | uint(currentBuffer[6])<<14)
 			}
+			state := b.pins[pin]
+			b.pinMutex.Unlock()
 
-			b.Publish(b.Event(fmt.Sprintf("PinState%v", pin)), b.pins[pin])
+			b.Publish(b.Event(fmt.Sprintf("PinState%v", pin)), state)
 		case I2CReply:
 			reply := I2cReply{
-				Address:  int(byte(currentBuffer[2]) | byte(currentBuffer[3])<<7),
-				Register: int(byte(currentBuffer[4]) | byte(currentBuffer[5])<<7),
+				Address:  int(currentBuffer[2]) | int(currentBuffer[3])<<7,
+				Register: int(currentBuffer[4]) | int(currentBuffer[5])<<7,
 				Data:     []byte{byte(currentBuffer[6]) | byte(currentBuffer[7])<<7},
 			}
 			for i := 8; i < len(currentBuffer); i = i + 2 {
@@ -455,10 +1141,31 @@ func (b *Client) process() (err error) {
 				}
 			}
 			b.FirmwareName = string(name[:])
+			b.firmwareMajor = int(currentBuffer[2])
+			b.firmwareMinor = int(currentBuffer[3])
+			b.firmwareReport = append([]byte{}, currentBuffer...)
 			b.Publish(b.Event("FirmwareQuery"), b.FirmwareName)
+		case SerialMessage:
+			if len(currentBuffer) < 4 || currentBuffer[2]&0xF0 != SerialModeReply {
+				break
+			}
+			reply := SerialReply{
+				Port: int(currentBuffer[2] & 0x0F),
+				Data: []byte{},
+			}
+			for i := 3; i+1 < len(currentBuffer)-1; i = i + 2 {
+				reply.Data = append(reply.Data,
+					byte(currentBuffer[i])|byte(currentBuffer[i+1])<<7,
+				)
+			}
+			b.Publish(b.Event("SerialReply"), reply)
 		case StringData:
 			str := currentBuffer[2:]
 			b.Publish(b.Event("StringData"), string(str[:len(str)-1]))
+		default:
+			data := make([]byte, len(currentBuffer))
+			copy(data, currentBuffer)
+			b.Publish(b.Event("SysexResponse"), data)
 		}
 	}
 	return
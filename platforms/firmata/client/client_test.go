@@ -2,6 +2,10 @@ package client
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
 	"testing"
 	"time"
 
@@ -125,6 +129,8 @@ func TestProcessAnalogRead0(t *testing.T) {
 
 	b.Once(b.Event("AnalogRead0"), func(data interface{}) {
 		gobottest.Assert(t, data, 675)
+		pin, _ := b.Pin(14)
+		gobottest.Assert(t, pin.Updated.IsZero(), false)
 		sem <- true
 	})
 
@@ -164,6 +170,8 @@ func TestProcessDigitalRead2(t *testing.T) {
 
 	b.Once(b.Event("DigitalRead2"), func(data interface{}) {
 		gobottest.Assert(t, data, 1)
+		pin, _ := b.Pin(2)
+		gobottest.Assert(t, pin.Updated.IsZero(), false)
 		sem <- true
 	})
 
@@ -176,6 +184,26 @@ func TestProcessDigitalRead2(t *testing.T) {
 	}
 }
 
+func TestProcessDigitalReadPullup(t *testing.T) {
+	sem := make(chan bool)
+	b := initTestFirmata()
+	b.pins[4].Mode = InputPullup
+	testReadData = []byte{0x90, 0x16, 0x00}
+
+	b.Once(b.Event("DigitalRead4"), func(data interface{}) {
+		gobottest.Assert(t, data, 1)
+		sem <- true
+	})
+
+	go b.process()
+
+	select {
+	case <-sem:
+	case <-time.After(10 * time.Millisecond):
+		t.Errorf("DigitalRead4 was not published")
+	}
+}
+
 func TestProcessDigitalRead4(t *testing.T) {
 	sem := make(chan bool)
 	b := initTestFirmata()
@@ -202,7 +230,7 @@ func TestProcessPinState13(t *testing.T) {
 	testReadData = []byte{240, 110, 13, 1, 1, 247}
 
 	b.Once(b.Event("PinState13"), func(data interface{}) {
-		gobottest.Assert(t, data, Pin{[]int{0, 1, 4}, 1, 0, 1, 127})
+		gobottest.Assert(t, data, Pin{[]int{0, 1, 4}, 1, 0, 1, 127, 0, []int{1, 1, 14}, time.Time{}})
 		sem <- true
 	})
 
@@ -215,6 +243,29 @@ func TestProcessPinState13(t *testing.T) {
 	}
 }
 
+func TestProcessCapabilitiesRefresh(t *testing.T) {
+	b := initTestFirmata()
+	b.pins[3].Mode = Pwm
+	b.pins[3].Value = 100
+	b.pins[0].Mode = Input
+
+	// a refresh while connected keeps the state of the pins
+	testReadData = testCapabilitiesResponse()
+	b.process()
+	gobottest.Assert(t, len(b.pins), 20)
+	gobottest.Assert(t, b.pins[3].Mode, Pwm)
+	gobottest.Assert(t, b.pins[3].Value, 100)
+	// unless the pin no longer supports its mode
+	gobottest.Assert(t, b.pins[0].Mode, Output)
+
+	// the handshake starts afresh
+	b.connected = false
+	testReadData = testCapabilitiesResponse()
+	b.process()
+	gobottest.Assert(t, b.pins[3].Mode, Output)
+	gobottest.Assert(t, b.pins[3].Value, 0)
+}
+
 func TestProcessI2cReply(t *testing.T) {
 	sem := make(chan bool)
 	b := initTestFirmata()
@@ -238,12 +289,56 @@ func TestProcessI2cReply(t *testing.T) {
 	}
 }
 
+func TestProcessI2cReplyTenBit(t *testing.T) {
+	sem := make(chan bool)
+	b := initTestFirmata()
+	testReadData = []byte{0xF0, 0x77, 0x25, 0x05, 0x10, 0x02, 0x01, 0x00, 0xF7}
+
+	b.Once(b.Event("I2cReply"), func(data interface{}) {
+		gobottest.Assert(t, data, I2cReply{
+			Address:  0x2A5,
+			Register: 0x110,
+			Data:     []byte{0x01},
+		})
+		sem <- true
+	})
+
+	go b.process()
+
+	select {
+	case <-sem:
+	case <-time.After(10 * time.Millisecond):
+		t.Errorf("I2cReply was not published")
+	}
+}
+
+func TestProcessSerialReply(t *testing.T) {
+	sem := make(chan bool)
+	b := initTestFirmata()
+	testReadData = []byte{240, 0x60, 0x48, 0x24, 0, 0x7F, 1, 247}
+
+	b.Once(b.Event("SerialReply"), func(data interface{}) {
+		gobottest.Assert(t, data, SerialReply{Port: 8, Data: []byte{'$', 0xFF}})
+		sem <- true
+	})
+
+	go b.process()
+
+	select {
+	case <-sem:
+	case <-time.After(10 * time.Millisecond):
+		t.Errorf("SerialReply was not published")
+	}
+}
+
 func TestProcessFirmwareQuery(t *testing.T) {
 	sem := make(chan bool)
 	b := initTestFirmata()
-	testReadData = []byte{240, 121, 2, 3, 83, 0, 116, 0, 97, 0, 110, 0, 100, 0, 97,
+	report := []byte{240, 121, 2, 3, 83, 0, 116, 0, 97, 0, 110, 0, 100, 0, 97,
 		0, 114, 0, 100, 0, 70, 0, 105, 0, 114, 0, 109, 0, 97, 0, 116, 0, 97, 0, 46,
 		0, 105, 0, 110, 0, 111, 0, 247}
+	testReadData = report
+	gobottest.Assert(t, New().RawFirmwareReport(), []byte(nil))
 
 	b.Once(b.Event("FirmwareQuery"), func(data interface{}) {
 		gobottest.Assert(t, data, "StandardFirmata.ino")
@@ -257,6 +352,12 @@ func TestProcessFirmwareQuery(t *testing.T) {
 	case <-time.After(10 * time.Millisecond):
 		t.Errorf("FirmwareQuery was not published")
 	}
+
+	name, major, minor := b.Firmware()
+	gobottest.Assert(t, name, "StandardFirmata.ino")
+	gobottest.Assert(t, major, 2)
+	gobottest.Assert(t, minor, 3)
+	gobottest.Assert(t, b.RawFirmwareReport(), report)
 }
 
 func TestProcessStringData(t *testing.T) {
@@ -278,6 +379,473 @@ func TestProcessStringData(t *testing.T) {
 	}
 }
 
+func TestProcessSysexResponse(t *testing.T) {
+	sem := make(chan bool)
+	b := initTestFirmata()
+	testReadData = []byte{240, 0x51, 1, 2, 247}
+
+	b.Once(b.Event("SysexResponse"), func(data interface{}) {
+		gobottest.Assert(t, data, []byte{240, 0x51, 1, 2, 247})
+		sem <- true
+	})
+
+	go b.process()
+
+	select {
+	case <-sem:
+	case <-time.After(10 * time.Millisecond):
+		t.Errorf("SysexResponse was not published")
+	}
+}
+
+func TestProcessRawSysex(t *testing.T) {
+	sem := make(chan bool)
+	b := initTestFirmata()
+	testReadData = []byte{240, 0x71, 'O', 'K', 247}
+
+	b.On(b.Event("RawSysex"), func(data interface{}) {
+		// the handshake responses of initTestFirmata may still be delivered
+		if data.([]byte)[1] == 0x71 {
+			gobottest.Assert(t, data, []byte{240, 0x71, 'O', 'K', 247})
+			sem <- true
+		}
+	})
+
+	go b.process()
+
+	select {
+	case <-sem:
+	case <-time.After(10 * time.Millisecond):
+		t.Errorf("RawSysex was not published")
+	}
+}
+
+func TestWriteSysex(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	gobottest.Assert(t, b.WriteSysex([]byte{0x51, 1}), nil)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{240, 0x51, 1, 247})
+}
+
+func TestWriteRaw(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	n, err := b.WriteRaw([]byte{0xF0, 0x51})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 2)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{0xF0, 0x51})
+}
+
+func TestI2cReadRegister(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cReadRegister(0x0B, 0x130, 33), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x0B, 0x08, 0x30, 0x02, 33, 0, 247})
+}
+
+func TestI2cReadContinuous(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cReadContinuous(0x0B, 0x130, 6), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x0B, 0x10, 0x30, 0x02, 6, 0, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cStopReading(0x0B), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x0B, 0x18, 247})
+}
+
+func TestI2cTenBit(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cRead(0x2A5|I2cTenBit, 2), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x25, 0x2D, 2, 0, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cWrite(0x2A5|I2cTenBit, []byte{1}), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x25, 0x25, 1, 0, 247})
+}
+
+func TestI2cRestart(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cRead(0x0B|I2cRestart, 2), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x0B, 0x48, 2, 0, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.I2cWrite(0x0B|I2cRestart, []byte{1}), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x76, 0x0B, 0x40, 1, 0, 247})
+}
+
+func TestProcessResync(t *testing.T) {
+	b := initTestFirmata()
+	errs := make(chan interface{}, 10)
+	b.On(b.Event("Error"), func(data interface{}) {
+		errs <- data
+	})
+	sem := make(chan interface{}, 10)
+	b.On(b.Event("AnalogRead0"), func(data interface{}) {
+		sem <- data
+	})
+
+	// garbage before a message, then a message cut short by a new message
+	testReadData = []byte{0x23, 0xF3, 0xE0, 0x23, 0x05, 0xE0, 0x01, 0xE0, 0x24, 0x05}
+	for i := 0; i < 3; i++ {
+		gobottest.Assert(t, b.process(), nil)
+	}
+
+	for _, expected := range []int{675, 676} {
+		select {
+		case data := <-sem:
+			gobottest.Assert(t, data, expected)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("AnalogRead0 was not published")
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case data := <-errs:
+			gobottest.Assert(t, data, ErrResync)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("Error was not published")
+		}
+	}
+}
+
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Debugf(format string, v ...interface{}) {
+	l.messages = append(l.messages, "debug: "+fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Infof(format string, v ...interface{}) {
+	l.messages = append(l.messages, "info: "+fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Errorf(format string, v ...interface{}) {
+	l.messages = append(l.messages, "error: "+fmt.Sprintf(format, v...))
+}
+
+func TestSetLogger(t *testing.T) {
+	b := initTestFirmata()
+	l := &testLogger{}
+	b.SetLogger(l)
+
+	testReadData = []byte{0x23, 0xE0, 0x23, 0x05}
+	gobottest.Assert(t, b.process(), nil)
+	gobottest.Assert(t, l.messages, []string{"debug: firmata: " + ErrResync.Error()})
+
+	b.SetLogger(nil)
+	testReadData = []byte{0x23, 0xE0, 0x23, 0x05}
+	gobottest.Assert(t, b.process(), nil)
+	gobottest.Assert(t, len(l.messages), 1)
+}
+
+func TestProcessSysexOverrun(t *testing.T) {
+	b := initTestFirmata()
+	errs := make(chan interface{}, 10)
+	b.On(b.Event("Error"), func(data interface{}) {
+		errs <- data
+	})
+	sem := make(chan interface{}, 1)
+	b.On(b.Event("StringData"), func(data interface{}) {
+		sem <- data
+	})
+
+	testReadData = append([]byte{0xF0, 0x71}, make([]byte, 2*MaxSysexSize)...)
+	testReadData = append(testReadData, 0xF7, 0xF0, 0x71, 0x48, 0x69, 0xF7)
+	gobottest.Assert(t, b.process(), nil)
+	gobottest.Assert(t, b.process(), nil)
+
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, "Hi")
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("StringData was not published")
+	}
+	select {
+	case data := <-errs:
+		gobottest.Assert(t, data, ErrSysexOverrun)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Error was not published")
+	}
+	gobottest.Assert(t, len(errs), 0)
+}
+
+func TestProcessSysexResync(t *testing.T) {
+	b := initTestFirmata()
+	sem := make(chan interface{}, 1)
+	b.On(b.Event("StringData"), func(data interface{}) {
+		sem <- data
+	})
+
+	testReadData = append([]byte{240, 0x71, 0x41, 0xE0, 0x23, 0x05, 240, 0x71},
+		append([]byte("ok"), 247)...)
+	for i := 0; i < 3; i++ {
+		gobottest.Assert(t, b.process(), nil)
+	}
+
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, "ok")
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("StringData was not published")
+	}
+}
+
+func TestDigitalWriteConcurrent(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+
+	var wg sync.WaitGroup
+	for pin := 2; pin < 18; pin++ {
+		wg.Add(1)
+		go func(pin int) {
+			defer wg.Done()
+			b.SetPinMode(pin, Output)
+			b.DigitalWrite(pin, 1)
+		}(pin)
+	}
+	wg.Wait()
+
+	for pin := 2; pin < 18; pin++ {
+		gobottest.Assert(t, b.Pins()[pin].Mode, Output)
+		gobottest.Assert(t, b.Pins()[pin].Value, 1)
+	}
+
+	// every message is three bytes, the last one for each port has all of
+	// its pins set
+	ports := map[byte][]byte{}
+	data := testWriteData.Bytes()
+	gobottest.Assert(t, len(data)%3, 0)
+	for i := 0; i < len(data); i += 3 {
+		if data[i] != PinMode {
+			ports[data[i]] = data[i+1 : i+3]
+		}
+	}
+	gobottest.Assert(t, ports, map[byte][]byte{
+		0x90: {0x7C, 0x01},
+		0x91: {0x7F, 0x01},
+		0x92: {0x03, 0x00},
+	})
+}
+
+func TestProcessCapabilitiesModes(t *testing.T) {
+	b := initTestFirmata()
+	gobottest.Assert(t, b.Pins()[3].SupportedModes, []int{Input, Output, Pwm, Servo})
+	gobottest.Assert(t, b.Pins()[18].SupportedModes, []int{Input, Output, Analog, I2c})
+}
+
+func TestProcessAnalogResolution(t *testing.T) {
+	b := initTestFirmata()
+	gobottest.Assert(t, b.Pins()[14].AnalogResolution, 10)
+	gobottest.Assert(t, b.Pins()[2].AnalogResolution, 0)
+}
+
+func TestProcessResolutions(t *testing.T) {
+	b := initTestFirmata()
+	gobottest.Assert(t, b.Pins()[3].Resolutions, []int{1, 1, 8, 14})
+	gobottest.Assert(t, b.Pins()[18].Resolutions, []int{1, 1, 10, 1})
+
+	pin, _ := b.Pin(3)
+	gobottest.Assert(t, pin.Resolutions, []int(nil))
+}
+
+func TestProcessAnalogMapping(t *testing.T) {
+	b := initTestFirmata()
+	gobottest.Assert(t, len(b.Pins()), 20)
+	gobottest.Assert(t, b.Pins()[19].AnalogChannel, 5)
+	gobottest.Assert(t, b.analogPins, []int{14, 15, 16, 17, 18, 19})
+	gobottest.Assert(t, b.PinMismatch(), nil)
+}
+
+func TestProcessAnalogMappingMismatch(t *testing.T) {
+	b := initTestFirmata()
+
+	// two pins short of the capability response
+	mapping := testAnalogMappingResponse()
+	testReadData = append(append([]byte{}, mapping[:len(mapping)-3]...), EndSysex)
+	gobottest.Assert(t, b.process(), nil)
+	gobottest.Assert(t, b.PinMismatch(), &PinMismatchError{Capabilities: 20, AnalogMapping: 18})
+	gobottest.Assert(t, len(b.Pins()), 20)
+	gobottest.Assert(t, b.Pins()[17].AnalogChannel, 3)
+	gobottest.Assert(t, b.Pins()[18].AnalogChannel, 127)
+	gobottest.Assert(t, b.Pins()[19].AnalogChannel, 127)
+	gobottest.Assert(t, b.analogPins, []int{14, 15, 16, 17})
+
+	// two entries past the capability response
+	testReadData = append(append([]byte{}, mapping[:len(mapping)-1]...), 6, 7, EndSysex)
+	gobottest.Assert(t, b.process(), nil)
+	gobottest.Assert(t, b.PinMismatch(), &PinMismatchError{Capabilities: 20, AnalogMapping: 22})
+	gobottest.Assert(t, len(b.Pins()), 20)
+	gobottest.Assert(t, b.analogPins, []int{14, 15, 16, 17, 18, 19})
+
+	testReadData = mapping
+	gobottest.Assert(t, b.process(), nil)
+	gobottest.Assert(t, b.PinMismatch(), nil)
+}
+
+func TestPinsCopy(t *testing.T) {
+	b := initTestFirmata()
+	pins := b.Pins()
+	pins[2].Mode = Servo
+	pins[2].SupportedModes[0] = Servo
+
+	gobottest.Refute(t, b.Pins()[2].Mode, Servo)
+	gobottest.Refute(t, b.Pins()[2].SupportedModes[0], Servo)
+}
+
+func TestPin(t *testing.T) {
+	b := initTestFirmata()
+	pin, ok := b.Pin(14)
+	gobottest.Assert(t, ok, true)
+	gobottest.Assert(t, pin.AnalogChannel, 0)
+	gobottest.Assert(t, pin.Mode, b.Pins()[14].Mode)
+	gobottest.Assert(t, len(pin.SupportedModes), 0)
+
+	_, ok = b.Pin(20)
+	gobottest.Assert(t, ok, false)
+	_, ok = b.Pin(-1)
+	gobottest.Assert(t, ok, false)
+}
+
+func TestDigitalWriteMulti(t *testing.T) {
+	b := initTestFirmata()
+	b.pins[3].Value = 1
+	testWriteData.Reset()
+	gobottest.Assert(t, b.DigitalWriteMulti(map[int]int{2: 1, 7: 1, 9: 1, 3: 1}), nil)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{0x90, 0x0C, 0x01, 0x91, 0x02, 0x00})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.DigitalWriteMulti(map[int]int{18: 1}), nil)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{0x92, 0x04, 0x00})
+}
+
+type eofConn struct {
+	readWriteCloser
+	data *bytes.Reader
+}
+
+func (c eofConn) Read(b []byte) (int, error) {
+	return c.data.Read(b)
+}
+
+func TestPollEOF(t *testing.T) {
+	b := initTestFirmata()
+	b.connection = eofConn{data: bytes.NewReader([]byte{0xE0, 0x23, 0x05, 0xE0})}
+	disconnected := make(chan interface{}, 1)
+	b.On(b.Event("Disconnect"), func(data interface{}) {
+		disconnected <- data
+	})
+	analog := make(chan interface{}, 1)
+	b.On(b.Event("AnalogRead0"), func(data interface{}) {
+		analog <- data
+	})
+
+	b.poll()
+	gobottest.Assert(t, b.Connected(), false)
+	select {
+	case data := <-disconnected:
+		gobottest.Assert(t, data, io.EOF)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Disconnect was not published")
+	}
+	select {
+	case data := <-analog:
+		gobottest.Assert(t, data, 675)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("AnalogRead0 was not published")
+	}
+}
+
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "read timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+// timeoutConn times out before each byte it reads.
+type timeoutConn struct {
+	readWriteCloser
+	data    *bytes.Reader
+	timeout *bool
+}
+
+func (c timeoutConn) Read(b []byte) (int, error) {
+	*c.timeout = !*c.timeout
+	if *c.timeout {
+		return 0, errTimeout{}
+	}
+	return c.data.Read(b[:1])
+}
+
+func TestPollReadTimeout(t *testing.T) {
+	b := initTestFirmata()
+	b.connection = timeoutConn{
+		data:    bytes.NewReader([]byte{0xE0, 0x23, 0x05}),
+		timeout: new(bool),
+	}
+	disconnected := make(chan interface{}, 1)
+	b.On(b.Event("Disconnect"), func(data interface{}) {
+		disconnected <- data
+	})
+	errs := make(chan interface{}, 10)
+	b.On(b.Event("Error"), func(data interface{}) {
+		errs <- data
+	})
+	analog := make(chan interface{}, 1)
+	b.On(b.Event("AnalogRead0"), func(data interface{}) {
+		analog <- data
+	})
+
+	// the timeouts within the message do not break it up
+	b.poll()
+	select {
+	case data := <-analog:
+		gobottest.Assert(t, data, 675)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("AnalogRead0 was not published")
+	}
+	select {
+	case data := <-disconnected:
+		gobottest.Assert(t, data, io.EOF)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Disconnect was not published")
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected error %v", err)
+	default:
+	}
+
+	// with fatal timeouts the first one ends polling
+	b = initTestFirmata()
+	b.SetFatalReadTimeouts(true)
+	b.connection = timeoutConn{
+		data:    bytes.NewReader([]byte{0xE0, 0x23, 0x05}),
+		timeout: new(bool),
+	}
+	disconnected = make(chan interface{}, 1)
+	b.On(b.Event("Disconnect"), func(data interface{}) {
+		disconnected <- data
+	})
+
+	b.poll()
+	gobottest.Assert(t, b.Connected(), false)
+	select {
+	case data := <-disconnected:
+		gobottest.Assert(t, data, errTimeout{})
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Disconnect was not published")
+	}
+}
+
 func TestConnect(t *testing.T) {
 	b := New()
 
@@ -309,6 +877,240 @@ func TestConnect(t *testing.T) {
 	gobottest.Assert(t, b.Connect(readWriteCloser{}), nil)
 }
 
+// handshakeConn answers the handshake queries of Connect, after serving the
+// stale bytes which were queued before the connection. Answers take 10ms, as
+// they would from a board.
+type handshakeConn struct {
+	mutex    sync.Mutex
+	data     []byte
+	ready    time.Time
+	deadline time.Time
+}
+
+func (c *handshakeConn) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ready = time.Now().Add(10 * time.Millisecond)
+	switch {
+	case bytes.Equal(p, []byte{ProtocolVersion}):
+		c.data = append(c.data, testProtocolResponse()...)
+	case bytes.Equal(p, []byte{StartSysex, FirmwareQuery, EndSysex}):
+		c.data = append(c.data, testFirmwareResponse()...)
+	case bytes.Equal(p, []byte{StartSysex, CapabilityQuery, EndSysex}):
+		c.data = append(c.data, testCapabilitiesResponse()...)
+	case bytes.Equal(p, []byte{StartSysex, AnalogMappingQuery, EndSysex}):
+		c.data = append(c.data, testAnalogMappingResponse()...)
+	}
+	return len(p), nil
+}
+
+func (c *handshakeConn) Read(p []byte) (int, error) {
+	for start := time.Now(); time.Since(start) < 100*time.Millisecond; time.Sleep(time.Millisecond) {
+		c.mutex.Lock()
+		if len(c.data) > 0 && time.Now().After(c.ready) {
+			n := copy(p, c.data)
+			c.data = c.data[n:]
+			c.mutex.Unlock()
+			return n, nil
+		}
+		deadline := c.deadline
+		c.mutex.Unlock()
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return 0, errors.New("i/o timeout")
+		}
+	}
+	return 0, io.EOF
+}
+
+func (c *handshakeConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *handshakeConn) Close() error { return nil }
+
+func TestConnectDiscardsStaleInput(t *testing.T) {
+	b := New()
+	// an analog mapping arriving before the capabilities cannot be parsed
+	conn := &handshakeConn{data: testAnalogMappingResponse()}
+	b.unread = []byte{0xF0, 0x6C}
+
+	gobottest.Assert(t, b.Connect(conn), nil)
+	gobottest.Assert(t, len(b.Pins()), len(initTestFirmata().Pins()))
+	gobottest.Assert(t, b.Pins()[14].AnalogChannel, 0)
+	b.Disconnect()
+}
+
+// serialConn is a handshakeConn without read deadlines, which discards its
+// pending input when flushed like a serial port.
+type serialConn struct {
+	conn    *handshakeConn
+	flushes int
+}
+
+func (c *serialConn) Read(p []byte) (int, error)  { return c.conn.Read(p) }
+func (c *serialConn) Write(p []byte) (int, error) { return c.conn.Write(p) }
+func (c *serialConn) Close() error                { return c.conn.Close() }
+
+func (c *serialConn) Flush() error {
+	c.conn.mutex.Lock()
+	defer c.conn.mutex.Unlock()
+	c.conn.data = nil
+	c.flushes++
+	return nil
+}
+
+func TestConnectFlushesStaleInput(t *testing.T) {
+	b := New()
+	conn := &serialConn{conn: &handshakeConn{data: testAnalogMappingResponse()}}
+
+	gobottest.Assert(t, b.Connect(conn), nil)
+	gobottest.Assert(t, conn.flushes, 1)
+	gobottest.Assert(t, len(b.Pins()), len(initTestFirmata().Pins()))
+	gobottest.Assert(t, b.Pins()[14].AnalogChannel, 0)
+	b.Disconnect()
+}
+
+func TestConnectReadChunkSize(t *testing.T) {
+	b := New()
+	b.SetReadChunkSize(64)
+	conn := &handshakeConn{}
+
+	gobottest.Assert(t, b.Connect(conn), nil)
+	gobottest.Assert(t, len(b.Pins()), len(initTestFirmata().Pins()))
+	b.Disconnect()
+}
+
+type countingCloser struct {
+	readWriteCloser
+	closes *int
+}
+
+func (c countingCloser) Close() error {
+	*c.closes++
+	return nil
+}
+
+func TestDisconnectTwice(t *testing.T) {
+	b := New()
+	gobottest.Assert(t, b.Disconnect(), nil)
+
+	closes := 0
+	b.connection = countingCloser{closes: &closes}
+	b.connected = true
+	gobottest.Assert(t, b.Disconnect(), nil)
+	gobottest.Assert(t, b.Disconnect(), nil)
+	gobottest.Assert(t, closes, 1)
+	gobottest.Assert(t, b.Connected(), false)
+}
+
+type chunkConn struct {
+	readWriteCloser
+	writes *[][]byte
+}
+
+func (c chunkConn) Write(p []byte) (int, error) {
+	*c.writes = append(*c.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+type writeErrorConn struct {
+	readWriteCloser
+}
+
+func (writeErrorConn) Write([]byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestSetPinModeWriteError(t *testing.T) {
+	b := initTestFirmata()
+	gobottest.Assert(t, b.SetPinMode(9, Pwm), nil)
+
+	b.connection = writeErrorConn{}
+	gobottest.Assert(t, b.SetPinMode(9, Servo), errors.New("write error"))
+	pin, _ := b.Pin(9)
+	gobottest.Assert(t, pin.Mode, Pwm)
+}
+
+func TestWriteUnknownPin(t *testing.T) {
+	b := New()
+	b.connection = readWriteCloser{}
+	gobottest.Assert(t, b.SetPinMode(13, Output), ErrUnknownPin)
+	gobottest.Assert(t, b.DigitalWrite(13, 1), ErrUnknownPin)
+	gobottest.Assert(t, b.DigitalWriteMulti(map[int]int{13: 1}), ErrUnknownPin)
+	gobottest.Assert(t, b.AnalogWrite(9, 100), ErrUnknownPin)
+
+	b = initTestFirmata()
+	gobottest.Assert(t, b.DigitalWrite(len(b.Pins()), 1), ErrUnknownPin)
+}
+
+func TestBatch(t *testing.T) {
+	b := initTestFirmata()
+	writes := [][]byte{}
+	b.connection = chunkConn{writes: &writes}
+
+	b.BeginBatch()
+	gobottest.Assert(t, b.SetPinMode(13, Output), nil)
+	b.BeginBatch()
+	gobottest.Assert(t, b.DigitalWrite(13, 1), nil)
+	gobottest.Assert(t, b.EndBatch(), nil)
+	n, err := b.WriteRaw([]byte{0xFF})
+	gobottest.Assert(t, n, 1)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(writes), 0)
+
+	gobottest.Assert(t, b.EndBatch(), nil)
+	gobottest.Assert(t, writes, [][]byte{{0xF4, 13, 1, 0x91, 0x20, 0x00, 0xFF}})
+
+	// writes are no longer held, and an unmatched EndBatch does nothing
+	gobottest.Assert(t, b.EndBatch(), nil)
+	gobottest.Assert(t, b.DigitalWrite(13, 0), nil)
+	gobottest.Assert(t, len(writes), 2)
+}
+
+func TestWriteChunkSize(t *testing.T) {
+	b := initTestFirmata()
+	writes := [][]byte{}
+	b.connection = chunkConn{writes: &writes}
+	b.SetWriteChunkSize(3)
+
+	gobottest.Assert(t, b.WriteSysex([]byte{0x51, 1, 2, 3}), nil)
+	gobottest.Assert(t, writes, [][]byte{{240, 0x51, 1}, {2, 3, 247}})
+
+	writes = writes[:0]
+	b.SetWriteChunkSize(0)
+	gobottest.Assert(t, b.WriteSysex([]byte{0x51, 1, 2, 3}), nil)
+	gobottest.Assert(t, writes, [][]byte{{240, 0x51, 1, 2, 3, 247}})
+}
+
+func TestSerial(t *testing.T) {
+	b := initTestFirmata()
+	testWriteData.Reset()
+	gobottest.Assert(t, b.SerialConfig(0x01, 9600, -1, -1), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x60, 0x11, 0x00, 0x4B, 0x00, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.SerialConfig(0x08, 57600, 10, 11), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x60, 0x18, 0x00, 0x42, 0x03, 10, 11, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.SerialWrite(0x08, []byte{'$', 0xFF}), nil)
+	gobottest.Assert(t, testWriteData.Bytes(),
+		[]byte{240, 0x60, 0x28, 0x24, 0, 0x7F, 1, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.SerialRead(0x08, 0), nil)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{240, 0x60, 0x38, 0, 247})
+
+	testWriteData.Reset()
+	gobottest.Assert(t, b.SerialRead(0x08, 200), nil)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{240, 0x60, 0x38, 0, 0x48, 1, 247})
+}
+
 func TestServoConfig(t *testing.T) {
 	b := New()
 	b.connection = readWriteCloser{}
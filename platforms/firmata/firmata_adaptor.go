@@ -1,16 +1,29 @@
 package firmata
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/tarm/serial"
 	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/drivers/aio"
+	"gobot.io/x/gobot/drivers/gpio"
+	"gobot.io/x/gobot/drivers/i2c"
 	"gobot.io/x/gobot/platforms/firmata/client"
 )
 
-type firmataBoard interface {
+// FirmataBoard is the interface the Adaptor uses to talk to the board. It is
+// implemented by *client.Client, which the Adaptor uses unless another
+// implementation is supplied using WithBoard. Further methods of the board,
+// such as Firmware or DigitalWriteMulti, are used when the board implements
+// them; otherwise the Adaptor falls back on the methods of FirmataBoard or
+// reports the feature as not supported.
+type FirmataBoard interface {
 	Connect(io.ReadWriteCloser) error
 	Disconnect() error
 	Pins() []client.Pin
@@ -23,39 +36,292 @@ type firmataBoard interface {
 	I2cWrite(int, []byte) error
 	I2cConfig(int) error
 	ServoConfig(int, int, int) error
-	Event(string) string
+	ProtocolVersionQuery() error
+	WriteSysex([]byte) error
+	Connected() bool
+	gobot.Eventer
 }
 
+// The Adaptor implements these gobot interfaces, so drivers written against
+// them can use it.
+var (
+	_ gobot.Adaptor      = (*Adaptor)(nil)
+	_ gobot.Porter       = (*Adaptor)(nil)
+	_ gpio.DigitalReader = (*Adaptor)(nil)
+	_ gpio.DigitalWriter = (*Adaptor)(nil)
+	_ gpio.PwmWriter     = (*Adaptor)(nil)
+	_ gpio.ServoWriter   = (*Adaptor)(nil)
+	_ aio.AnalogReader   = (*Adaptor)(nil)
+	_ i2c.I2c            = (*Adaptor)(nil)
+
+	_ FirmataBoard     = (*client.Client)(nil)
+	_ pinReader        = (*client.Client)(nil)
+	_ multiWriter      = (*client.Client)(nil)
+	_ registerReader   = (*client.Client)(nil)
+	_ pinStateQuerier  = (*client.Client)(nil)
+	_ firmwareReporter = (*client.Client)(nil)
+	_ rawFirmware      = (*client.Client)(nil)
+	_ pinMismatcher    = (*client.Client)(nil)
+	_ serialBoard      = (*client.Client)(nil)
+	_ rawWriter        = (*client.Client)(nil)
+	_ batchWriter      = (*client.Client)(nil)
+	_ continuousReader = (*client.Client)(nil)
+)
+
+// Errors
+var (
+	ErrSysexFeatureRegistered = errors.New("a sysex feature is already registered for this command")
+	ErrHandshakeTimeout       = errors.New("timed out waiting for the board to complete the handshake")
+	ErrUnknownReference       = errors.New("analog reference voltage is not set")
+)
+
+// DefaultHandshakeTimeout is the default time Connect waits for the board to
+// answer the handshake queries.
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// DefaultAnalogSettle is the default time AnalogRead waits for the first
+// sample after enabling reporting for a pin.
+const DefaultAnalogSettle = 100 * time.Millisecond
+
 // Adaptor is the Gobot Adaptor for Firmata based boards
 type Adaptor struct {
 	name         string
 	port         string
-	board        firmataBoard
+	label        string
+	board        FirmataBoard
 	conn         io.ReadWriteCloser
 	openCommPort func(port string) (io.ReadWriteCloser, error)
+	baud         int
+	baudRates    []int
+	lastError    error
+	errorMutex   sync.Mutex
+	health       *healthMonitor
+	features     map[byte]SysexFeature
+	featureMutex sync.Mutex
+	handled      FirmataBoard
+	handlers     []func()
+	neopixel     neopixelStrip
+	inverted     map[int]bool
+	activeHigh   bool
+	aliases      map[string]int
+	servoRanges  map[int][2]int
+	writeQueue   writeQueue
+	i2c          i2cTransactions
+	i2cStreams   i2cStreams
+	timeouts     Timeouts
+	openedConn   bool
+	adopted      bool
+	detached     bool
+	disconnected bool
+	closeMutex   sync.Mutex
+	connecting   chan error
+	minFirmware  [2]int
+	reporting    *reporting
+	eventBuffer  int
+	i2cBlockSize int
+	debouncers   map[int]*debouncer
+	logger       Logger
+	reference    float64
+	pullups      []string
+	pullupPorts  map[int]bool
+	reportPins   reportPins
+	pinMutex     sync.RWMutex
+	modeLocks    map[int]*sync.Mutex
+	modeMutex    sync.Mutex
+	modeRetries  int
+	readLinger   time.Duration
+	manualModes  bool
+	rawAnalog    bool
+	dropEvents   bool
+	dropping     *droppingEventer
+	safeValues   map[string]byte
+	readChunk    int
+	fatalReads   bool
+	writeChunk   int
+	flowControl  bool
+	reconnect    *reconnector
+	eventPrefix  string
+	boardPrefix  string
 	gobot.Eventer
 }
 
+// Option configures an Adaptor. Options are passed to NewAdaptor alongside
+// the port and connection arguments.
+type Option func(*Adaptor)
+
+// WithBoard makes the Adaptor use board instead of a new client.Client.
+func WithBoard(board FirmataBoard) Option {
+	return func(f *Adaptor) {
+		f.board = board
+	}
+}
+
+// WithLabel sets a label for the Adaptor, such as "arm controller", which its
+// log messages show instead of the port. Port returns it when no port is
+// given, so that the Adaptor is not shown blank when a connection is passed
+// to NewAdaptor. The label is not used to open the port.
+func WithLabel(label string) Option {
+	return func(f *Adaptor) {
+		f.label = label
+	}
+}
+
+// WithManualModes makes DigitalWrite, DigitalWriteMulti, PwmWrite and
+// ServoWrite write to the pin in whatever mode it is in, instead of switching
+// it to the mode they need first, for callers which set the modes themselves,
+// such as custom modes of ConfigurableFirmata which the Adaptor does not know.
+func WithManualModes(manual bool) Option {
+	return func(f *Adaptor) {
+		f.manualModes = manual
+	}
+}
+
+// WithHandshakeTimeout sets how long Connect waits for the board to answer the
+// handshake queries before giving up with ErrHandshakeTimeout. A zero timeout
+// waits forever.
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.Handshake = d
+	}
+}
+
+// WithAnalogSettle sets how long AnalogRead and ReadAllAnalog wait for the
+// first sample after enabling reporting for a pin. They return as soon as the
+// sample arrives, so a generous value only costs time when the board is slow
+// to answer. On timeout the last known value is returned.
+func WithAnalogSettle(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.AnalogSettle = d
+	}
+}
+
+// WithAnalogReference sets the reference voltage of the analog inputs of the
+// board, such as 5 or 3.3, which AnalogVoltage scales readings to.
+func WithAnalogReference(volts float64) Option {
+	return func(f *Adaptor) {
+		f.reference = volts
+	}
+}
+
+// WithMinFirmware makes Connect fail if the firmware on the board reports a
+// version older than major.minor.
+func WithMinFirmware(major, minor int) Option {
+	return func(f *Adaptor) {
+		f.minFirmware = [2]int{major, minor}
+	}
+}
+
+// WithEventBuffer sets the number of events the Adaptor, and the client it
+// creates for the board, queue before delivering them to subscribers. The
+// default is 1. Unless WithDropEvents is set, events are never dropped: when
+// the queue is full, the publisher blocks until a subscriber takes the next
+// event, which for board events stalls the read loop. A larger buffer absorbs
+// bursts from fast sensors. A size below 1 keeps the default.
+func WithEventBuffer(size int) Option {
+	return func(f *Adaptor) {
+		if size < 0 {
+			size = 0
+		}
+		f.eventBuffer = size
+	}
+}
+
+// WithLogger sets the Logger used by the Adaptor and by the client it creates
+// for the board. Nothing is logged by default.
+func WithLogger(l Logger) Option {
+	return func(f *Adaptor) {
+		if l == nil {
+			l = nopLogger{}
+		}
+		f.logger = l
+	}
+}
+
+// WithRawAnalogPins makes AnalogRead, AnalogReadWait and AnalogVoltage take
+// their pin argument as the board pin number, rather than as an analog channel
+// offset by 14 to find the pin. The channel to report is then looked up in
+// the analog mapping reported by the board.
+func WithRawAnalogPins(raw bool) Option {
+	return func(f *Adaptor) {
+		f.rawAnalog = raw
+	}
+}
+
+// WithReadChunkSize makes the client the Adaptor creates for the board read
+// from the connection in chunks of up to size bytes, which are buffered and
+// save a read call per message part on busy links. The default of zero reads
+// each message part on its own.
+func WithReadChunkSize(size int) Option {
+	return func(f *Adaptor) {
+		f.readChunk = size
+	}
+}
+
+// WithFatalReadTimeouts makes the client the Adaptor creates for the board
+// treat a read timeout of the connection as the loss of the connection, which
+// publishes the "Disconnect" event and starts reconnecting if enabled, such
+// as to notice a board which went silent through the read timeout of its
+// serial port. By default a read timeout means the board had nothing to send,
+// and reading goes on.
+func WithFatalReadTimeouts(fatal bool) Option {
+	return func(f *Adaptor) {
+		f.fatalReads = fatal
+	}
+}
+
+// WithWriteChunkSize makes the client the Adaptor creates for the board write
+// to the connection at most size bytes at a time, splitting longer messages,
+// for links which lose larger writes. The default of zero writes each message
+// at once.
+func WithWriteChunkSize(size int) Option {
+	return func(f *Adaptor) {
+		f.writeChunk = size
+	}
+}
+
 // NewAdaptor returns a new Firmata Adaptor which optionally accepts:
 //
 //	string: port the Adaptor uses to connect to a serial port with a baude rate of 57600
 //	io.ReadWriteCloser: connection the Adaptor uses to communication with the hardware
+//	Option: a configuration option such as WithHealthInterval
 //
 // If an io.ReadWriteCloser is not supplied, the Adaptor will open a connection
-// to a serial port with a baude rate of 57600. If an io.ReadWriteCloser
-// is supplied, then the Adaptor will use the provided io.ReadWriteCloser and use the
-// string port as a label to be displayed in the log and api.
+// to a serial port with a baude rate of 57600, or the one found with
+// WithAutoBaud. If an io.ReadWriteCloser is supplied, then the Adaptor will
+// use the provided io.ReadWriteCloser and use the string port as a label to be
+// displayed in the log and api.
 func NewAdaptor(args ...interface{}) *Adaptor {
 	f := &Adaptor{
-		name:  "Firmata",
-		port:  "",
-		conn:  nil,
-		board: client.New(),
-		openCommPort: func(port string) (io.ReadWriteCloser, error) {
-			return serial.OpenPort(&serial.Config{Name: port, Baud: 57600})
-		},
-		Eventer: gobot.NewEventer(),
+		name:         "Firmata",
+		port:         "",
+		conn:         nil,
+		board:        client.New(),
+		health:       newHealthMonitor(),
+		reconnect:    newReconnector(),
+		features:     make(map[byte]SysexFeature),
+		inverted:     make(map[int]bool),
+		aliases:      make(map[string]int),
+		servoRanges:  make(map[int][2]int),
+		modeLocks:    make(map[int]*sync.Mutex),
+		debouncers:   make(map[int]*debouncer),
+		pullupPorts:  make(map[int]bool),
+		logger:       nopLogger{},
+		timeouts:     DefaultTimeouts,
+		readLinger:   DefaultReadLinger,
+		reporting:    newReporting(),
+		i2cBlockSize: i2cBlockMax,
+		baud:         DefaultBaudRate,
+		Eventer:      gobot.NewEventer(),
 	}
+	f.openCommPort = f.openSerialPort
+
+	f.AddEvent("Health")
+	f.AddEvent("Firmware")
+	f.AddEvent("Disconnect")
+	f.AddEvent("ReportingRestored")
+	f.AddEvent("Reconnect")
+	f.AddEvent("Warning")
+	f.AddEvent("SerialRead")
 
 	for _, arg := range args {
 		switch arg.(type) {
@@ -63,43 +329,435 @@ func NewAdaptor(args ...interface{}) *Adaptor {
 			f.port = arg.(string)
 		case io.ReadWriteCloser:
 			f.conn = arg.(io.ReadWriteCloser)
+		case Option:
+			arg.(Option)(f)
+		}
+	}
+
+	if c, ok := f.board.(*client.Client); ok {
+		c.SetLogger(f.logger)
+		c.SetReadChunkSize(f.readChunk)
+		c.SetWriteChunkSize(f.writeChunk)
+		c.SetFatalReadTimeouts(f.fatalReads)
+	}
+
+	if f.eventBuffer > 0 {
+		f.Eventer = bufferedEventer(f.Eventer, f.eventBuffer)
+		if c, ok := f.board.(*client.Client); ok {
+			c.Eventer = bufferedEventer(c.Eventer, f.eventBuffer)
+		}
+	}
+	if c, ok := f.board.(*client.Client); ok && f.dropEvents {
+		f.dropping = newDroppingEventer(c.Eventer, f.eventBuffer, f.eventPrefix)
+		c.Eventer = f.dropping
+	}
+	if f.eventPrefix != "" {
+		f.Eventer = &prefixEventer{Eventer: f.Eventer, prefix: f.eventPrefix}
+		if c, ok := f.board.(*client.Client); ok {
+			c.Eventer = &prefixEventer{Eventer: c.Eventer, prefix: f.eventPrefix}
+			f.boardPrefix = f.eventPrefix
 		}
 	}
 
 	return f
 }
 
-// Connect starts a connection to the board.
+// bufferedEventer returns a new Eventer with a buffer of size which has the
+// same events as eventer.
+func bufferedEventer(eventer gobot.Eventer, size int) gobot.Eventer {
+	e := gobot.NewEventerWithBuffer(size)
+	for name := range eventer.Events() {
+		e.AddEvent(name)
+	}
+	return e
+}
+
+// Connect starts a connection to the board. If the board does not complete the
+// handshake within the handshake timeout, Connect returns ErrHandshakeTimeout
+// and closes the connection it opened, so that the abandoned handshake ends. A
+// connection passed to NewAdaptor is left open, and a retry waits for its
+// abandoned handshake to end until the caller closes it. On a reconnect,
+// reporting is enabled again for the pins read, watched or subscribed to
+// before, and the number of analog channels and digital ports restored is
+// published with the "ReportingRestored" event. A firmware bug such as a
+// client.PinMismatchError does not fail Connect, but is published with the
+// "Warning" event and returned by LastError.
 func (f *Adaptor) Connect() (err error) {
-	if f.conn == nil {
-		sp, e := f.openCommPort(f.Port())
-		if e != nil {
-			return e
+	atomic.StoreInt32(&f.reconnect.stopped, 0)
+	f.closeMutex.Lock()
+	f.disconnected = false
+	f.closeMutex.Unlock()
+	if f.conn == nil && len(f.baudRates) > 0 {
+		if err = f.connectAutoBaud(); err != nil {
+			f.logger.Errorf("firmata: connecting to %v: %v", f.Label(), err)
+			return err
+		}
+	} else {
+		if f.conn == nil {
+			sp, e := f.openCommPort(f.port)
+			if e != nil {
+				return e
+			}
+			f.conn = sp
+			f.openedConn = true
+		}
+		if err = f.setupFlowControl(); err != nil {
+			f.logger.Errorf("firmata: enabling flow control on %v: %v", f.Label(), err)
+			return err
+		}
+		f.conn = f.health.wrap(f.conn)
+		if f.adopted && f.board.Connected() {
+			f.logger.Debugf("firmata: %v is already connected, skipping the handshake", f.Label())
+		} else if err = f.connectBoard(); err != nil {
+			f.logger.Errorf("firmata: connecting to %v: %v", f.Label(), err)
+			return err
 		}
-		f.conn = sp
 	}
-	if err = f.board.Connect(f.conn); err != nil {
+	if err = f.checkFirmware(); err != nil {
+		f.logger.Errorf("firmata: %v", err)
+		f.board.Disconnect()
+		if f.openedConn {
+			f.conn = nil
+			f.openedConn = false
+		}
 		return err
 	}
-	return
+
+	f.setLastError(nil)
+	f.resetI2c()
+	f.handleBoardEvents()
+	f.startHealth()
+	f.publishFirmware()
+	f.checkPinMismatch()
+	if err = f.restoreReporting(); err != nil {
+		f.logger.Errorf("firmata: restoring reporting: %v", err)
+		return err
+	}
+	if err = f.restoreI2c(); err != nil {
+		f.logger.Errorf("firmata: restoring i2c: %v", err)
+		return err
+	}
+	if err = f.setupPullups(); err != nil {
+		return err
+	}
+	if err = f.setupReportPins(); err != nil {
+		return err
+	}
+	f.logger.Infof("firmata: connected to %v", f.Label())
+	if err = f.flushWrites(); err != nil {
+		f.logger.Errorf("firmata: flushing queued writes: %v", err)
+	}
+	return nil
+}
+
+// connectBoard connects the board, giving up after the handshake timeout. A
+// handshake abandoned by an earlier timeout must have returned before a new
+// one is started, so that two of them never run against the same board.
+func (f *Adaptor) connectBoard() error {
+	if f.connecting != nil {
+		select {
+		case <-f.connecting:
+			f.connecting = nil
+		case <-time.After(f.timeouts.Handshake):
+			return ErrHandshakeTimeout
+		}
+	}
+	if f.timeouts.Handshake <= 0 {
+		return f.board.Connect(f.conn)
+	}
+
+	conn := f.conn
+	result := make(chan error, 1)
+	go func() {
+		result <- f.board.Connect(conn)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(f.timeouts.Handshake):
+		f.connecting = result
+		if f.openedConn {
+			conn.Close()
+			f.conn = nil
+			f.openedConn = false
+		}
+		return ErrHandshakeTimeout
+	}
+}
+
+// Firmware is the data published with the Adaptor's "Firmware" event.
+type Firmware struct {
+	Name  string
+	Major int
+	Minor int
+}
+
+// firmwareReporter is implemented by boards which report the name and version
+// of their firmware, such as client.Client.
+type firmwareReporter interface {
+	Firmware() (string, int, int)
+}
+
+// firmware returns the firmware reported by the board, and whether the board
+// reports one.
+func (f *Adaptor) firmware() (Firmware, bool) {
+	b, ok := f.board.(firmwareReporter)
+	if !ok {
+		return Firmware{}, false
+	}
+	name, major, minor := b.Firmware()
+	return Firmware{Name: name, Major: major, Minor: minor}, true
+}
+
+// rawFirmware is implemented by boards which keep the firmware report as
+// received, such as client.Client.
+type rawFirmware interface {
+	RawFirmwareReport() []byte
+}
+
+// RawFirmwareReport returns the last REPORT_FIRMWARE message of the board as
+// received, from the START_SYSEX byte to the END_SYSEX byte, for custom
+// firmware which packs data after its name that the Firmware event leaves
+// out. Returns nil before the board has reported its firmware, or if the board
+// does not keep the report.
+func (f *Adaptor) RawFirmwareReport() []byte {
+	if b, ok := f.board.(rawFirmware); ok {
+		return b.RawFirmwareReport()
+	}
+	return nil
+}
+
+// publishFirmware publishes the "Firmware" event, once for each successful
+// Connect.
+func (f *Adaptor) publishFirmware() {
+	firmware, _ := f.firmware()
+	f.Publish(f.Event("Firmware"), firmware)
+}
+
+// checkFirmware verifies the firmware is at least the minimum version. A board
+// which does not report its firmware is not checked.
+func (f *Adaptor) checkFirmware() error {
+	firmware, ok := f.firmware()
+	if !ok {
+		return nil
+	}
+	major, minor := firmware.Major, firmware.Minor
+	min := f.minFirmware
+	if major < min[0] || (major == min[0] && minor < min[1]) {
+		return fmt.Errorf("firmware version %v.%v is older than the required %v.%v",
+			major, minor, min[0], min[1])
+	}
+	return nil
+}
+
+// handleBoardEvents subscribes the Adaptor to the events of its board. The
+// subscriptions outlive a Disconnect, so they are only made once per board.
+func (f *Adaptor) handleBoardEvents() {
+	if f.handled == f.board {
+		return
+	}
+	f.releaseBoardEvents()
+	f.handled = f.board
+
+	f.onBoardEvent("Error", func(data interface{}) {
+		if e, ok := data.(error); ok {
+			f.setLastError(e)
+		}
+	})
+	f.onBoardEvent("SysexResponse", func(data interface{}) {
+		f.dispatchSysex(data.([]byte))
+	})
+	f.onBoardEvent("Disconnect", func(data interface{}) {
+		f.Publish(f.Event("Disconnect"), data)
+		if !f.board.Connected() {
+			go f.startReconnect()
+		}
+	})
+	if _, ok := f.board.(serialBoard); ok {
+		f.onBoardEvent("SerialReply", func(data interface{}) {
+			f.publishSerialRead(data.(client.SerialReply))
+		})
+	}
+	f.onBoardEvent("ProtocolVersion", f.health.measureRTT)
 }
 
-// Disconnect closes the io connection to the board
+// onBoardEvent calls handler with the data of each name event of the board
+// until releaseBoardEvents is called.
+func (f *Adaptor) onBoardEvent(name string, handler func(interface{})) {
+	f.handlers = append(f.handlers, handleEvent(f.board, f.board.Event(name), handler))
+}
+
+// releaseBoardEvents removes the handlers set by handleBoardEvents.
+func (f *Adaptor) releaseBoardEvents() {
+	for _, cancel := range f.handlers {
+		cancel()
+	}
+	f.handlers = nil
+	f.handled = nil
+}
+
+// Disconnect closes the io connection to the board. Writes still in progress
+// on other goroutines are waited for first, so that a final command such as
+// stopping a motor is not cut off by the close. The wait is bounded by the
+// flush timeout, see WithFlushTimeout, after which the connection is closed
+// regardless and ErrFlushTimeout is returned. Calling Disconnect again before
+// the next Connect does nothing and returns nil.
 func (f *Adaptor) Disconnect() (err error) {
-	if f.board != nil {
-		return f.board.Disconnect()
+	f.stopReconnect()
+	f.stopHealth()
+	if !f.markDisconnected() {
+		return nil
+	}
+	if f.board != nil && !f.detached {
+		flushErr := f.drainWrites()
+		if flushErr != nil {
+			f.logger.Errorf("firmata: %v", flushErr)
+		}
+		if err = f.board.Disconnect(); err != nil {
+			f.logger.Errorf("firmata: disconnecting from %v: %v", f.Label(), err)
+			return err
+		}
+		f.logger.Infof("firmata: disconnected from %v", f.Label())
+		return flushErr
 	}
 	return nil
 }
 
-// Finalize terminates the firmata connection
+// markDisconnected records that the Adaptor is disconnected, and reports
+// whether it was connected before.
+func (f *Adaptor) markDisconnected() bool {
+	f.closeMutex.Lock()
+	defer f.closeMutex.Unlock()
+	if f.disconnected {
+		return false
+	}
+	f.disconnected = true
+	return true
+}
+
+// isDisconnected reports whether Disconnect was called since the last
+// Connect.
+func (f *Adaptor) isDisconnected() bool {
+	f.closeMutex.Lock()
+	defer f.closeMutex.Unlock()
+	return f.disconnected
+}
+
+// Finalize terminates the firmata connection, after writing the values set
+// with WithSafeShutdown. Like Disconnect, it does nothing once the Adaptor is
+// disconnected, apart from ending the delivery of board events queued by
+// WithDropEvents, which stops for good.
 func (f *Adaptor) Finalize() (err error) {
-	err = f.Disconnect()
-	return err
+	if f.detached {
+		return nil
+	}
+	if f.dropping != nil {
+		defer f.dropping.stop()
+	}
+	if f.isDisconnected() {
+		return nil
+	}
+	f.finishI2c(I2cShutdownTimeout)
+	safeErr := f.safeShutdown(SafeShutdownTimeout)
+	if err = f.Disconnect(); err != nil {
+		return err
+	}
+	return safeErr
+}
+
+// SoftReset disables all analog and digital reporting and returns every pin to
+// its power-on mode, analog for analog capable pins and output for the rest,
+// without resetting the board. Pins which do not support that mode, and pins
+// claimed by I2C, are left alone. Unlike a SystemReset, the connection and the
+// firmware and capability information already gathered are kept. Watches and
+// subscriptions stop receiving values until reporting is enabled again, while
+// the next DigitalRead or AnalogRead enables it for its pin. Ending a watch or
+// subscription made before SoftReset does not disable reporting enabled after
+// it.
+func (f *Adaptor) SoftReset() error {
+	pins := f.board.Pins()
+	i2c := f.i2cStarted()
+
+	for p := range pins {
+		mode := client.Output
+		if pins[p].AnalogChannel != 127 {
+			mode = client.Analog
+		}
+		if !supportsMode(pins[p], mode) || pins[p].Mode == client.I2c ||
+			(i2c && supportsMode(pins[p], client.I2c)) {
+			continue
+		}
+		if _, err := f.ensureMode(p, mode); err != nil {
+			return err
+		}
+	}
+
+	// StandardFirmata enables reporting when a pin is set to analog mode, so
+	// reporting is disabled once the modes are set
+	for port := 0; port*8 < len(pins); port++ {
+		if err := f.board.ReportDigital(port, 0); err != nil {
+			return err
+		}
+	}
+	for p := range pins {
+		if pins[p].AnalogChannel != 127 {
+			if err := f.board.ReportAnalog(pins[p].AnalogChannel, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	f.reporting.reset()
+	return nil
+}
+
+// LastError returns the most recent error reported by the board's background
+// read loop, or nil if there has been none since the last successful Connect.
+func (f *Adaptor) LastError() error {
+	f.errorMutex.Lock()
+	defer f.errorMutex.Unlock()
+	return f.lastError
+}
+
+func (f *Adaptor) setLastError(err error) {
+	f.errorMutex.Lock()
+	defer f.errorMutex.Unlock()
+	f.lastError = err
+}
+
+// FirmwareName returns the name reported by the firmware on the board.
+func (f *Adaptor) FirmwareName() string {
+	firmware, _ := f.firmware()
+	return firmware.Name
+}
+
+// FirmwareVersion returns the version reported by the firmware on the board.
+func (f *Adaptor) FirmwareVersion() (major int, minor int) {
+	firmware, _ := f.firmware()
+	return firmware.Major, firmware.Minor
+}
+
+// Port returns the Firmata Adaptors port, or the label set with WithLabel if
+// no port was given, as when a connection is passed to NewAdaptor.
+func (f *Adaptor) Port() string {
+	if f.port == "" {
+		return f.label
+	}
+	return f.port
 }
 
-// Port returns the Firmata Adaptors port
-func (f *Adaptor) Port() string { return f.port }
+// Label returns the label the Adaptor is shown with in its log messages: the
+// one set with WithLabel, or else its port, or else its name.
+func (f *Adaptor) Label() string {
+	if f.label != "" {
+		return f.label
+	}
+	if f.port != "" {
+		return f.port
+	}
+	return f.name
+}
 
 // Name returns the Firmata Adaptors name
 func (f *Adaptor) Name() string { return f.name }
@@ -114,21 +772,30 @@ func (f *Adaptor) ServoConfig(pin string, min, max int) error {
 		return err
 	}
 
-	return f.board.ServoConfig(p, max, min)
+	if err = f.board.ServoConfig(p, max, min); err != nil {
+		return err
+	}
+
+	f.pinMutex.Lock()
+	defer f.pinMutex.Unlock()
+	f.servoRanges[p] = [2]int{min, max}
+	return nil
 }
 
 // ServoWrite writes the 0-180 degree angle to the specified pin.
 func (f *Adaptor) ServoWrite(pin string, angle byte) (err error) {
+	if f.queueWrite(func() error { return f.ServoWrite(pin, angle) }) {
+		return nil
+	}
+	defer f.beginWrite()()
+
 	p, err := strconv.Atoi(pin)
 	if err != nil {
 		return err
 	}
 
-	if f.board.Pins()[p].Mode != client.Servo {
-		err = f.board.SetPinMode(p, client.Servo)
-		if err != nil {
-			return err
-		}
+	if err = f.ensureWriteMode(p, client.Servo); err != nil {
+		return err
 	}
 	err = f.board.AnalogWrite(p, int(angle))
 	return
@@ -136,39 +803,133 @@ func (f *Adaptor) ServoWrite(pin string, angle byte) (err error) {
 
 // PwmWrite writes the 0-254 value to the specified pin
 func (f *Adaptor) PwmWrite(pin string, level byte) (err error) {
+	if f.queueWrite(func() error { return f.PwmWrite(pin, level) }) {
+		return nil
+	}
+	defer f.beginWrite()()
+
 	p, err := strconv.Atoi(pin)
 	if err != nil {
 		return err
 	}
 
-	if f.board.Pins()[p].Mode != client.Pwm {
-		err = f.board.SetPinMode(p, client.Pwm)
-		if err != nil {
-			return err
-		}
+	if err = f.ensureWriteMode(p, client.Pwm); err != nil {
+		return err
 	}
 	err = f.board.AnalogWrite(p, int(level))
 	return
 }
 
+// PwmWritePercent writes a duty cycle given in percent to the specified pin.
+// Values below 0 or above 100 are clamped to that range.
+func (f *Adaptor) PwmWritePercent(pin string, pct float64) error {
+	if !(pct > 0) {
+		pct = 0
+	} else if pct > 100 {
+		pct = 100
+	}
+	return f.PwmWrite(pin, byte(math.Floor(pct*255/100+0.5)))
+}
+
 // DigitalWrite writes a value to the pin. Acceptable values are 1 or 0.
 func (f *Adaptor) DigitalWrite(pin string, level byte) (err error) {
+	if f.queueWrite(func() error { return f.DigitalWrite(pin, level) }) {
+		return nil
+	}
+	defer f.beginWrite()()
+
 	p, err := strconv.Atoi(pin)
 	if err != nil {
 		return
 	}
 
-	if f.board.Pins()[p].Mode != client.Output {
-		err = f.board.SetPinMode(p, client.Output)
+	if err = f.ensureWriteMode(p, client.Output); err != nil {
+		return
+	}
+
+	if f.isInverted(p) {
+		level = invertLevel(level)
+	}
+
+	err = f.board.DigitalWrite(p, int(level))
+	return
+}
+
+// DigitalWriteMulti writes the same level to several pins. Pins that share a
+// port are written with a single message, so they change at the same time.
+func (f *Adaptor) DigitalWriteMulti(pins []string, level byte) (err error) {
+	if f.queueWrite(func() error { return f.DigitalWriteMulti(pins, level) }) {
+		return nil
+	}
+	defer f.beginWrite()()
+
+	values := map[int]int{}
+	for _, pin := range pins {
+		p, err := strconv.Atoi(pin)
 		if err != nil {
+			return err
+		}
+		values[p] = int(level)
+	}
+
+	for p := range values {
+		if err = f.ensureWriteMode(p, client.Output); err != nil {
 			return
 		}
+		if f.isInverted(p) {
+			values[p] = int(invertLevel(level))
+		}
 	}
 
-	err = f.board.DigitalWrite(p, int(level))
+	if b, ok := f.board.(multiWriter); ok {
+		return b.DigitalWriteMulti(values)
+	}
+	for p, value := range values {
+		if err = f.board.DigitalWrite(p, value); err != nil {
+			return
+		}
+	}
 	return
 }
 
+// multiWriter is implemented by boards which can write several pins of a port
+// with one message, such as client.Client.
+type multiWriter interface {
+	DigitalWriteMulti(map[int]int) error
+}
+
+// SetInverted marks the pin as active-low. DigitalWrite to an inverted pin
+// drives the line to the opposite level, and DigitalRead reports the inverse
+// of the line level. The inversion is applied by the Adaptor, not the board.
+func (f *Adaptor) SetInverted(pin string, inverted bool) error {
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+
+	f.pinMutex.Lock()
+	defer f.pinMutex.Unlock()
+	if inverted {
+		f.inverted[p] = true
+	} else {
+		delete(f.inverted, p)
+	}
+	return nil
+}
+
+func (f *Adaptor) isInverted(pin int) bool {
+	f.pinMutex.RLock()
+	defer f.pinMutex.RUnlock()
+	return f.inverted[pin]
+}
+
+func invertLevel(level byte) byte {
+	if level == 0 {
+		return 1
+	}
+	return 0
+}
+
 // DigitalRead retrieves digital value from specified pin.
 // Returns -1 if the response from the board has timed out
 func (f *Adaptor) DigitalRead(pin string) (val int, err error) {
@@ -177,72 +938,261 @@ func (f *Adaptor) DigitalRead(pin string) (val int, err error) {
 		return
 	}
 
-	if f.board.Pins()[p].Mode != client.Input {
-		if err = f.board.SetPinMode(p, client.Input); err != nil {
-			return
+	if _, err = f.ensureMode(p, client.Input); err != nil {
+		return
+	}
+
+	state, _ := f.pin(p)
+	val = state.Value
+	if d := f.debouncer(p); d != nil {
+		val = d.value()
+	} else {
+		armed, err := f.armRead(digitalReport, p/8)
+		if err != nil {
+			return 0, err
 		}
-		if err = f.board.ReportDigital(p, 1); err != nil {
-			return
+		if armed {
+			<-time.After(10 * time.Millisecond)
+			state, _ = f.pin(p)
+			val = state.Value
 		}
-		<-time.After(10 * time.Millisecond)
 	}
-
-	return f.board.Pins()[p].Value, nil
+	if f.isInverted(p) {
+		val = int(invertLevel(byte(val)))
+	}
+	return val, nil
 }
 
-// AnalogRead retrieves value from analog pin.
-// Returns -1 if the response from the board has timed out
+// AnalogRead retrieves value from analog pin. When reporting is not yet
+// enabled for the pin, it waits for the first sample up to the time set by
+// WithAnalogSettle.
 func (f *Adaptor) AnalogRead(pin string) (val int, err error) {
-	p, err := strconv.Atoi(pin)
+	return f.AnalogReadWait(pin, f.timeouts.AnalogSettle)
+}
+
+// AnalogReadWait is AnalogRead with the time to wait for the first sample
+// given for this call.
+func (f *Adaptor) AnalogReadWait(pin string, settle time.Duration) (val int, err error) {
+	p, channel, err := f.analogPin(pin)
 	if err != nil {
 		return
 	}
+	return f.readAnalog(p, channel, settle)
+}
+
+// readAnalog reads board pin p, which reports on the given analog channel.
+func (f *Adaptor) readAnalog(p int, channel int, settle time.Duration) (val int, err error) {
+	if _, err = f.ensureMode(p, client.Analog); err != nil {
+		return
+	}
+	if !f.readArmed(analogReport, channel) {
+		events := f.board.Subscribe()
+		armed, err := f.armRead(analogReport, channel)
+		if err != nil {
+			unsubscribe(f.board, events)
+			return 0, err
+		}
+		if armed {
+			f.awaitEvents(events, []string{f.boardEvent(fmt.Sprintf("AnalogRead%v", channel))}, settle)
+		} else {
+			unsubscribe(f.board, events)
+		}
+	}
+
+	state, _ := f.pin(p)
+	return state.Value, nil
+}
 
-	p = f.digitalPin(p)
+// SetAnalogInput switches the analog pin, named as in AnalogRead, to analog
+// mode and enables its reporting without waiting for a sample, leaving the
+// readings to OnAnalogRead handlers, watches and subscriptions. Reporting is
+// held as a read holds it, so a later AnalogRead returns the last reading at
+// once, but stays on without reads.
+func (f *Adaptor) SetAnalogInput(pin string) error {
+	p, channel, err := f.analogPin(pin)
+	if err != nil {
+		return err
+	}
+	if _, err = f.ensureMode(p, client.Analog); err != nil {
+		return err
+	}
+	return f.holdRead(analogReport, channel)
+}
 
-	if f.board.Pins()[p].Mode != client.Analog {
-		if err = f.board.SetPinMode(p, client.Analog); err != nil {
+// AnalogVoltage reads the analog pin as AnalogRead does and converts the
+// reading to volts, using the resolution reported by the board and the
+// reference set with WithAnalogReference. Boards which do not report a
+// resolution are taken to have 10-bit inputs. Returns ErrUnknownReference if
+// no reference was set.
+func (f *Adaptor) AnalogVoltage(pin string) (float64, error) {
+	if f.reference <= 0 {
+		return 0, ErrUnknownReference
+	}
+	val, err := f.AnalogRead(pin)
+	if err != nil {
+		return 0, err
+	}
+
+	p, _, _ := f.analogPin(pin)
+	state, _ := f.pin(p)
+	bits := state.AnalogResolution
+	if bits <= 0 {
+		bits = 10
+	}
+	return float64(val) * f.reference / float64(int(1)<<uint(bits)-1), nil
+}
+
+// awaitEvents waits until every event in names has been received on events,
+// or until timeout, and then unsubscribes events from the board.
+func (f *Adaptor) awaitEvents(events chan *gobot.Event, names []string, timeout time.Duration) {
+	defer unsubscribe(f.board, events)
+
+	pending := make(map[string]bool)
+	for _, name := range names {
+		pending[name] = true
+	}
+	expired := time.After(timeout)
+	for len(pending) > 0 {
+		select {
+		case evt := <-events:
+			delete(pending, evt.Name)
+		case <-expired:
 			return
 		}
+	}
+}
+
+// ReadAllAnalog returns the latest value of every analog pin, keyed by analog
+// channel as used by AnalogRead. Pins which are not yet in analog mode are
+// switched to it and have reporting enabled first. The analog pins are taken
+// from the analog mapping reported by the board. If a pin fails, reporting
+// enabled by the call for the pins before it is disabled again.
+func (f *Adaptor) ReadAllAnalog() (values map[string]int, err error) {
+	pins := f.board.Pins()
+	analog := []int{}
+	for p := range pins {
+		if pins[p].AnalogChannel != 127 {
+			analog = append(analog, p)
+		}
+	}
 
-		if err = f.board.ReportAnalog(p, 1); err != nil {
+	armed := []int{}
+	defer func() {
+		if err == nil {
 			return
 		}
-		<-time.After(10 * time.Millisecond)
+		for _, channel := range armed {
+			if e := f.disarmRead(analogReport, channel); e != nil {
+				f.logger.Debugf("firmata: disabling reporting of analog channel %v: %v", channel, e)
+			}
+		}
+	}()
+
+	enabled := []string{}
+	events := f.board.Subscribe()
+	for _, p := range analog {
+		channel := pins[p].AnalogChannel
+		taken := false
+		if _, err = f.ensureMode(p, client.Analog); err == nil {
+			taken, err = f.armRead(analogReport, channel)
+		}
+		if err != nil {
+			unsubscribe(f.board, events)
+			return nil, err
+		}
+		if taken {
+			armed = append(armed, channel)
+			enabled = append(enabled, f.boardEvent(fmt.Sprintf("AnalogRead%v", channel)))
+		}
 	}
+	f.awaitEvents(events, enabled, f.timeouts.AnalogSettle)
 
-	return f.board.Pins()[p].Value, nil
+	pins = f.board.Pins()
+	values = make(map[string]int)
+	for _, p := range analog {
+		values[strconv.Itoa(pins[p].AnalogChannel)] = pins[p].Value
+	}
+	return
 }
 
-// digitalPin converts pin number to digital mapping
-func (f *Adaptor) digitalPin(pin int) int {
-	return pin + 14
-}
+// ensureMode sets the pin to mode unless it is already in it, and reports
+// whether the mode was changed. A pin with its pull-up enabled already counts
+// as an input. Returns ErrInvalidPin for a pin the board does not have,
+// ErrNotReady before the board has reported its pins, and ErrNotSupported if
+// the firmware does not support mode on any pin. The check and the change are
+// made under a lock of the pin, so that of concurrent callers only one changes
+// the mode, while changes of other pins, which may wait for the board to
+// confirm them, go ahead.
+func (f *Adaptor) ensureMode(pin int, mode int) (changed bool, err error) {
+	lock := f.modeLock(pin)
+	lock.Lock()
+	defer lock.Unlock()
 
-// I2cStart starts an i2c device at specified address
-func (f *Adaptor) I2cStart(address int) (err error) {
-	return f.board.I2cConfig(0)
-}
+	state, ok := f.pin(pin)
+	if !ok {
+		return false, f.missingPin()
+	}
 
-// I2cRead returns size bytes from the i2c device
-// Returns an empty array if the response from the board has timed out
-func (f *Adaptor) I2cRead(address int, size int) (data []byte, err error) {
-	ret := make(chan []byte)
+	current := state.Mode
+	if current == mode || (mode == client.Input && current == client.InputPullup) {
+		return false, nil
+	}
+	if err = f.requireMode(mode); err != nil {
+		return false, err
+	}
+	if err = f.setPinMode(pin, mode); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	if err = f.board.I2cRead(address, size); err != nil {
-		return
+// ensureWriteMode is ensureMode for the write methods, which leave the mode
+// alone with WithManualModes.
+func (f *Adaptor) ensureWriteMode(pin int, mode int) error {
+	if f.manualModes {
+		return nil
 	}
+	_, err := f.ensureMode(pin, mode)
+	return err
+}
 
-	f.Once(f.board.Event("I2cReply"), func(data interface{}) {
-		ret <- data.(client.I2cReply).Data
-	})
+// modeLock returns the lock serializing the mode changes of pin.
+func (f *Adaptor) modeLock(pin int) *sync.Mutex {
+	f.modeMutex.Lock()
+	defer f.modeMutex.Unlock()
 
-	data = <-ret
+	lock, ok := f.modeLocks[pin]
+	if !ok {
+		lock = &sync.Mutex{}
+		f.modeLocks[pin] = lock
+	}
+	return lock
+}
 
-	return
+// digitalPin converts pin number to digital mapping
+func (f *Adaptor) digitalPin(pin int) int {
+	return pin + 14
 }
 
-// I2cWrite writes data to i2c device
-func (f *Adaptor) I2cWrite(address int, data []byte) (err error) {
-	return f.board.I2cWrite(address, data)
+// analogPin returns the board pin and the analog channel addressed by the pin
+// argument of AnalogRead. Unless WithRawAnalogPins is set, pin is the channel.
+// Returns ErrInvalidPin for a raw pin the board does not have or which has no
+// analog channel.
+func (f *Adaptor) analogPin(pin string) (p int, channel int, err error) {
+	n, err := strconv.Atoi(pin)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !f.rawAnalog {
+		return f.digitalPin(n), n, nil
+	}
+
+	state, ok := f.pin(n)
+	if !ok {
+		return 0, 0, f.missingPin()
+	}
+	if state.AnalogChannel == 127 {
+		return 0, 0, ErrInvalidPin
+	}
+	return n, state.AnalogChannel, nil
 }
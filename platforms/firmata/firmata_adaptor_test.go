@@ -5,28 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"gobot.io/x/gobot"
-	"gobot.io/x/gobot/drivers/aio"
-	"gobot.io/x/gobot/drivers/gpio"
-	"gobot.io/x/gobot/drivers/i2c"
 	"gobot.io/x/gobot/gobottest"
 	"gobot.io/x/gobot/platforms/firmata/client"
 )
 
-var _ gobot.Adaptor = (*Adaptor)(nil)
-
-var _ gpio.DigitalReader = (*Adaptor)(nil)
-var _ gpio.DigitalWriter = (*Adaptor)(nil)
-var _ aio.AnalogReader = (*Adaptor)(nil)
-var _ gpio.PwmWriter = (*Adaptor)(nil)
-var _ gpio.ServoWriter = (*Adaptor)(nil)
-
-var _ i2c.I2c = (*Adaptor)(nil)
-
 type readWriteCloser struct{}
 
 func (readWriteCloser) Write(p []byte) (int, error) {
@@ -51,42 +42,199 @@ func (readWriteCloser) Close() error {
 	return nil
 }
 
+type closeNotifier struct {
+	readWriteCloser
+	closed chan bool
+}
+
+func (c *closeNotifier) Close() error {
+	c.closed <- true
+	return nil
+}
+
 type mockFirmataBoard struct {
 	disconnectError error
+	disconnected    bool
+	connect         func() error
+	analogWrite     func(int, int)
+	i2cWrite        func(int, []byte)
+	pinStateQuery   func(int) client.Pin
+	pinStateSilent  func(int) bool
+	setPinMode      func(int, int) error
 	gobot.Eventer
-	pins []client.Pin
+	mutex    *sync.Mutex
+	pins     []client.Pin
+	sysex    *[][]byte
+	i2c      *[][]byte
+	i2cReads *[][3]int
+	streams  *[][4]int
+	configs  *int
+	reports  *[][3]int
+	modes    *[][2]int
+	serial   *[][]int
 }
 
 func newMockFirmataBoard() *mockFirmataBoard {
 	m := &mockFirmataBoard{
 		Eventer:         gobot.NewEventer(),
+		mutex:           &sync.Mutex{},
 		disconnectError: nil,
 		pins:            make([]client.Pin, 100),
+		sysex:           &[][]byte{},
+		i2c:             &[][]byte{},
+		i2cReads:        &[][3]int{},
+		streams:         &[][4]int{},
+		configs:         new(int),
+		reports:         &[][3]int{},
+		modes:           &[][2]int{},
+		serial:          &[][]int{},
 	}
 
 	m.pins[1].Value = 1
 	m.pins[15].Value = 133
 
 	m.AddEvent("I2cReply")
+	m.AddEvent("SerialReply")
+	m.AddEvent("Error")
+	m.AddEvent("ProtocolVersion")
+	m.AddEvent("SysexResponse")
+	m.AddEvent("RawSysex")
+	m.AddEvent("StringData")
+	m.AddEvent("Disconnect")
+	m.AddEvent("CapabilityQuery")
+	m.AddEvent("AnalogMappingQuery")
 	return m
 }
 
-func (mockFirmataBoard) Connect(io.ReadWriteCloser) error { return nil }
+func (m mockFirmataBoard) Connect(io.ReadWriteCloser) error {
+	if m.connect != nil {
+		return m.connect()
+	}
+	return nil
+}
 func (m mockFirmataBoard) Disconnect() error {
 	return m.disconnectError
 }
 func (m mockFirmataBoard) Pins() []client.Pin {
 	return m.pins
 }
-func (mockFirmataBoard) AnalogWrite(int, int) error      { return nil }
-func (mockFirmataBoard) SetPinMode(int, int) error       { return nil }
-func (mockFirmataBoard) ReportAnalog(int, int) error     { return nil }
-func (mockFirmataBoard) ReportDigital(int, int) error    { return nil }
-func (mockFirmataBoard) DigitalWrite(int, int) error     { return nil }
-func (mockFirmataBoard) I2cRead(int, int) error          { return nil }
-func (mockFirmataBoard) I2cWrite(int, []byte) error      { return nil }
-func (mockFirmataBoard) I2cConfig(int) error             { return nil }
+func (m mockFirmataBoard) Pin(pin int) (client.Pin, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if pin < 0 || pin >= len(m.pins) {
+		return client.Pin{}, false
+	}
+	return m.pins[pin], true
+}
+func (m mockFirmataBoard) AnalogWrite(pin int, value int) error {
+	m.pins[pin].Value = value
+	if m.analogWrite != nil {
+		m.analogWrite(pin, value)
+	}
+	return nil
+}
+func (m mockFirmataBoard) SetPinMode(pin int, mode int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.setPinMode != nil {
+		if err := m.setPinMode(pin, mode); err != nil {
+			return err
+		}
+	}
+	m.pins[pin].Mode = mode
+	*m.modes = append(*m.modes, [2]int{pin, mode})
+	return nil
+}
+func (m mockFirmataBoard) ReportAnalog(pin int, state int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	*m.reports = append(*m.reports, [3]int{int(client.ReportAnalog), pin, state})
+	return nil
+}
+func (m mockFirmataBoard) ReportDigital(pin int, state int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	*m.reports = append(*m.reports, [3]int{int(client.ReportDigital), pin, state})
+	return nil
+}
+func (m mockFirmataBoard) DigitalWrite(pin int, value int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pins[pin].Value = value
+	return nil
+}
+func (m mockFirmataBoard) DigitalWriteMulti(values map[int]int) error {
+	for pin, value := range values {
+		m.pins[pin].Value = value
+	}
+	return nil
+}
+func (mockFirmataBoard) I2cRead(int, int) error { return nil }
+func (m mockFirmataBoard) I2cReadRegister(address int, register int, size int) error {
+	*m.i2cReads = append(*m.i2cReads, [3]int{address, register, size})
+	return nil
+}
+func (m mockFirmataBoard) I2cReadContinuous(address int, register int, size int) error {
+	*m.streams = append(*m.streams, [4]int{int(client.I2CModeContinuousRead), address, register, size})
+	return nil
+}
+func (m mockFirmataBoard) I2cStopReading(address int) error {
+	*m.streams = append(*m.streams, [4]int{int(client.I2CModeStopReading), address, 0, 0})
+	return nil
+}
+func (m mockFirmataBoard) I2cWrite(address int, data []byte) error {
+	*m.i2c = append(*m.i2c, data)
+	if m.i2cWrite != nil {
+		m.i2cWrite(address, data)
+	}
+	return nil
+}
+func (m mockFirmataBoard) I2cConfig(int) error {
+	*m.configs++
+	return nil
+}
 func (mockFirmataBoard) ServoConfig(int, int, int) error { return nil }
+func (mockFirmataBoard) ProtocolVersionQuery() error     { return nil }
+func (m mockFirmataBoard) SerialConfig(port int, baud int, rx int, tx int) error {
+	*m.serial = append(*m.serial, []int{int(client.SerialModeConfig), port, baud, rx, tx})
+	return nil
+}
+func (m mockFirmataBoard) SerialWrite(port int, data []byte) error {
+	call := []int{int(client.SerialModeWrite), port}
+	for _, b := range data {
+		call = append(call, int(b))
+	}
+	*m.serial = append(*m.serial, call)
+	return nil
+}
+func (m mockFirmataBoard) SerialRead(port int, maxBytes int) error {
+	*m.serial = append(*m.serial, []int{int(client.SerialModeRead), port, maxBytes})
+	return nil
+}
+func (m mockFirmataBoard) PinStateQuery(pin int) error {
+	if m.pinStateSilent != nil && m.pinStateSilent(pin) {
+		return nil
+	}
+	m.mutex.Lock()
+	state := m.pins[pin]
+	m.mutex.Unlock()
+	if m.pinStateQuery != nil {
+		state = m.pinStateQuery(pin)
+	}
+	go m.Publish(fmt.Sprintf("PinState%v", pin), state)
+	return nil
+}
+func (m mockFirmataBoard) WriteSysex(data []byte) error {
+	*m.sysex = append(*m.sysex, data)
+	return nil
+}
+func (m mockFirmataBoard) Connected() bool { return !m.disconnected }
+func (mockFirmataBoard) Firmware() (string, int, int) {
+	return "StandardFirmata.ino", 2, 5
+}
+func (mockFirmataBoard) RawFirmwareReport() []byte {
+	return []byte{0xF0, 0x79, 2, 5, 'S', 0, 0xF7}
+}
 
 func initTestAdaptor() *Adaptor {
 	a := NewAdaptor("/dev/null")
@@ -112,6 +260,22 @@ func TestAdaptorFinalize(t *testing.T) {
 	gobottest.Assert(t, a.Finalize(), errors.New("close error"))
 }
 
+func TestAdaptorDisconnectTwice(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.(*mockFirmataBoard).disconnectError = errors.New("close error")
+	gobottest.Assert(t, a.Disconnect(), errors.New("close error"))
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Finalize(), nil)
+
+	// a new Connect makes the next Disconnect close the board again
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, a.Disconnect(), errors.New("close error"))
+
+	a = NewAdaptor("/dev/null")
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Disconnect(), nil)
+}
+
 func TestAdaptorConnect(t *testing.T) {
 	var openSP = func(port string) (io.ReadWriteCloser, error) {
 		return &readWriteCloser{}, nil
@@ -134,6 +298,228 @@ func TestAdaptorConnect(t *testing.T) {
 
 }
 
+func TestAdaptorConnectHandshakeTimeout(t *testing.T) {
+	closed := make(chan bool, 1)
+	a := NewAdaptor("/dev/null", WithHandshakeTimeout(10*time.Millisecond))
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		return &closeNotifier{closed: closed}, nil
+	}
+	board := newMockFirmataBoard()
+	block := make(chan struct{})
+	defer close(block)
+	var connects int32
+	board.connect = func() error {
+		atomic.AddInt32(&connects, 1)
+		<-block
+		return nil
+	}
+	a.board = board
+
+	gobottest.Assert(t, a.Connect(), ErrHandshakeTimeout)
+	gobottest.Assert(t, <-closed, true)
+	gobottest.Assert(t, a.conn, nil)
+
+	// a retry does not start a second handshake while the first one runs
+	gobottest.Assert(t, a.Connect(), ErrHandshakeTimeout)
+	gobottest.Assert(t, atomic.LoadInt32(&connects), int32(1))
+}
+
+func TestAdaptorConnectHandshakeTimeoutSuppliedConn(t *testing.T) {
+	closed := make(chan bool, 1)
+	a := NewAdaptor(&closeNotifier{closed: closed}, WithHandshakeTimeout(10*time.Millisecond))
+	board := newMockFirmataBoard()
+	block := make(chan struct{})
+	var connects int32
+	board.connect = func() error {
+		atomic.AddInt32(&connects, 1)
+		<-block
+		return nil
+	}
+	a.board = board
+
+	gobottest.Assert(t, a.Connect(), ErrHandshakeTimeout)
+	select {
+	case <-closed:
+		t.Fatal("a connection passed to NewAdaptor must not be closed")
+	default:
+	}
+	gobottest.Refute(t, a.conn, nil)
+
+	// a retry waits for the abandoned handshake
+	gobottest.Assert(t, a.Connect(), ErrHandshakeTimeout)
+	gobottest.Assert(t, atomic.LoadInt32(&connects), int32(1))
+
+	// once the abandoned handshake has returned a retry may proceed
+	close(block)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, atomic.LoadInt32(&connects), int32(2))
+}
+
+func TestAdaptorMinFirmware(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithMinFirmware(2, 5))
+	a.board = newMockFirmataBoard()
+	gobottest.Assert(t, a.Connect(), nil)
+
+	a = NewAdaptor(&readWriteCloser{}, WithMinFirmware(2, 6))
+	a.board = newMockFirmataBoard()
+	gobottest.Assert(t, a.Connect(),
+		errors.New("firmware version 2.5 is older than the required 2.6"))
+
+	a = NewAdaptor(&readWriteCloser{}, WithMinFirmware(3, 0))
+	a.board = newMockFirmataBoard()
+	gobottest.Refute(t, a.Connect(), nil)
+}
+
+func TestAdaptorFirmware(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.FirmwareName(), "StandardFirmata.ino")
+	major, minor := a.FirmwareVersion()
+	gobottest.Assert(t, major, 2)
+	gobottest.Assert(t, minor, 5)
+	gobottest.Assert(t, a.RawFirmwareReport(), []byte{0xF0, 0x79, 2, 5, 'S', 0, 0xF7})
+
+	a.board = client.New()
+	gobottest.Assert(t, a.RawFirmwareReport(), []byte(nil))
+}
+
+func TestAdaptorFirmwareEvent(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{})
+	a.board = newMockFirmataBoard()
+	sem := make(chan Firmware, 1)
+	a.Once(a.Event("Firmware"), func(data interface{}) {
+		sem <- data.(Firmware)
+	})
+	gobottest.Assert(t, a.Connect(), nil)
+
+	select {
+	case firmware := <-sem:
+		gobottest.Assert(t, firmware, Firmware{Name: "StandardFirmata.ino", Major: 2, Minor: 5})
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Firmware was not published")
+	}
+}
+
+func TestAdaptorWithEventBuffer(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithEventBuffer(64))
+	gobottest.Assert(t, a.Event("Health"), "Health")
+	gobottest.Assert(t, a.board.Event("I2cReply"), "I2cReply")
+
+	a = NewAdaptor("/dev/null", WithEventBuffer(-1), WithDropEvents(true))
+	gobottest.Assert(t, a.eventBuffer, 0)
+	gobottest.Assert(t, a.board.Event("I2cReply"), "I2cReply")
+}
+
+func TestAdaptorWithBoard(t *testing.T) {
+	board := newMockFirmataBoard()
+	a := NewAdaptor(&readWriteCloser{}, WithBoard(board))
+	gobottest.Assert(t, a.board, FirmataBoard(board))
+	gobottest.Assert(t, a.Connect(), nil)
+}
+
+func TestAdaptorWithCoreBoard(t *testing.T) {
+	board := newMockFirmataBoard()
+	// the wrapper hides the methods beyond FirmataBoard
+	a := NewAdaptor(&readWriteCloser{}, WithBoard(struct{ FirmataBoard }{board}),
+		WithMinFirmware(2, 5), WithModeRetries(2))
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, a.FirmwareName(), "")
+
+	gobottest.Assert(t, a.DigitalWriteMulti([]string{"2", "3"}, 1), nil)
+	gobottest.Assert(t, board.pins[2].Value, 1)
+	gobottest.Assert(t, board.pins[3].Value, 1)
+	gobottest.Assert(t, board.pins[2].Mode, client.Output)
+
+	val, err := a.DigitalRead("2")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+	_, err = a.DigitalRead("100")
+	gobottest.Assert(t, err, ErrInvalidPin)
+
+	gobottest.Assert(t, a.i2cReadRegister(0x40, 0x10, 2), nil)
+	gobottest.Assert(t, *board.i2c, [][]byte{{0x10}})
+}
+
+func TestAdaptorSoftReset(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{})
+	board := newMockFirmataBoard()
+	board.pins = make([]client.Pin, 10)
+	for i := range board.pins {
+		board.pins[i].AnalogChannel = 127
+		board.pins[i].Mode = client.Input
+		board.pins[i].SupportedModes = []int{client.Input, client.Output}
+	}
+	board.pins[7].SupportedModes = []int{client.Input, client.Output, client.I2c}
+	board.pins[8].SupportedModes = []int{client.Input}
+	board.pins[9].AnalogChannel = 0
+	board.pins[9].SupportedModes = []int{client.Input, client.Analog}
+	a.board = board
+	a.Connect()
+	a.I2cStart(0x10)
+	a.acquireReport(analogReport, 0)
+	*board.reports = nil
+
+	gobottest.Assert(t, a.SoftReset(), nil)
+	gobottest.Assert(t, *board.reports, [][3]int{
+		{int(client.ReportDigital), 0, 0},
+		{int(client.ReportDigital), 1, 0},
+		{int(client.ReportAnalog), 0, 0},
+	})
+	gobottest.Assert(t, len(a.reporting.counts), 0)
+	gobottest.Assert(t, board.pins[0].Mode, client.Output)
+	// pins claimed by I2C or without the mode keep their mode
+	gobottest.Assert(t, board.pins[7].Mode, client.Input)
+	gobottest.Assert(t, board.pins[8].Mode, client.Input)
+	gobottest.Assert(t, board.pins[9].Mode, client.Analog)
+}
+
+func TestAdaptorSoftResetEndsWatches(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+
+	stale, err := a.Watch("10", func(int) {})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, a.SoftReset(), nil)
+
+	cancel, err := a.Watch("11", func(int) {})
+	gobottest.Assert(t, err, nil)
+	*reports = nil
+
+	// the watch made before the reset no longer holds the port
+	stale()
+	gobottest.Assert(t, len(*reports), 0)
+	gobottest.Assert(t, a.IsReporting("11"), true)
+
+	cancel()
+	gobottest.Assert(t, *reports, [][3]int{{int(client.ReportDigital), 1, 0}})
+}
+
+func TestAdaptorSoftResetRearmsAnalogRead(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithAnalogSettle(time.Millisecond))
+	board := newMockFirmataBoard()
+	a.board = board
+	a.Connect()
+	reports := board.reports
+
+	a.AnalogRead("1")
+	gobottest.Assert(t, a.SoftReset(), nil)
+	*reports = nil
+	a.AnalogRead("1")
+	gobottest.Assert(t, len(*reports), 1)
+	gobottest.Assert(t, (*reports)[0][2], 1)
+}
+
+func TestAdaptorLastError(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.LastError(), nil)
+
+	a.board.Publish(a.board.Event("Error"), errors.New("read error"))
+	<-time.After(10 * time.Millisecond)
+	gobottest.Assert(t, a.LastError(), errors.New("read error"))
+
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, a.LastError(), nil)
+}
+
 func TestAdaptorServoWrite(t *testing.T) {
 	a := initTestAdaptor()
 	a.ServoWrite("1", 50)
@@ -144,11 +530,105 @@ func TestAdaptorPwmWrite(t *testing.T) {
 	a.PwmWrite("1", 50)
 }
 
+func TestAdaptorPwmWritePercent(t *testing.T) {
+	a := initTestAdaptor()
+	for pct, expected := range map[float64]int{
+		0: 0, 50: 128, 100: 255, 101.5: 255, -3: 0, math.NaN(): 0,
+	} {
+		gobottest.Assert(t, a.PwmWritePercent("3", pct), nil)
+		gobottest.Assert(t, a.board.Pins()[3].Value, expected)
+	}
+	gobottest.Assert(t, a.board.Pins()[3].Mode, client.Pwm)
+}
+
 func TestAdaptorDigitalWrite(t *testing.T) {
 	a := initTestAdaptor()
 	a.DigitalWrite("1", 1)
 }
 
+func TestAdaptorDigitalWriteConcurrent(t *testing.T) {
+	a := initTestAdaptor()
+	modes := a.board.(*mockFirmataBoard).modes
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				a.DigitalWrite("13", byte(j%2))
+				a.DigitalWrite(strconv.Itoa(20+i), 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// each pin was switched to output once
+	gobottest.Assert(t, len(*modes), 21)
+	gobottest.Assert(t, a.board.Pins()[13].Mode, client.Output)
+}
+
+func TestAdaptorEnsureMode(t *testing.T) {
+	modes := []int{client.Input, client.Output, client.Analog, client.Pwm, client.Servo, client.InputPullup}
+	for _, from := range modes {
+		for _, to := range modes {
+			a := initTestAdaptor()
+			board := a.board.(*mockFirmataBoard)
+			board.pins[3].Mode = from
+
+			changed, err := a.ensureMode(3, to)
+			gobottest.Assert(t, err, nil)
+			expected := from != to && !(to == client.Input && from == client.InputPullup)
+			gobottest.Assert(t, changed, expected)
+			if expected {
+				gobottest.Assert(t, *board.modes, [][2]int{{3, to}})
+				gobottest.Assert(t, board.pins[3].Mode, to)
+			} else {
+				gobottest.Assert(t, len(*board.modes), 0)
+				gobottest.Assert(t, board.pins[3].Mode, from)
+			}
+		}
+	}
+}
+
+func TestAdaptorEnsureModeInvalidPin(t *testing.T) {
+	a := initTestAdaptor()
+	for _, pin := range []int{-1, 100} {
+		_, err := a.ensureMode(pin, client.Output)
+		gobottest.Assert(t, err, ErrInvalidPin)
+	}
+	gobottest.Assert(t, a.DigitalWrite("100", 1), ErrInvalidPin)
+	_, err := a.DigitalRead("100")
+	gobottest.Assert(t, err, ErrInvalidPin)
+}
+
+func TestAdaptorPinsNotReady(t *testing.T) {
+	a := NewAdaptor("/dev/null")
+	gobottest.Assert(t, a.DigitalWrite("13", 1), ErrNotReady)
+	gobottest.Assert(t, a.PwmWrite("9", 100), ErrNotReady)
+	_, err := a.DigitalRead("2")
+	gobottest.Assert(t, err, ErrNotReady)
+	_, err = a.Pin("13")
+	gobottest.Assert(t, err, ErrNotReady)
+
+	a = NewAdaptor("/dev/null", WithRawAnalogPins(true))
+	_, err = a.AnalogRead("14")
+	gobottest.Assert(t, err, ErrNotReady)
+}
+
+func TestAdaptorDigitalWriteMulti(t *testing.T) {
+	a := initTestAdaptor()
+	a.SetInverted("3", true)
+	gobottest.Assert(t, a.DigitalWriteMulti([]string{"2", "3", "9"}, 1), nil)
+	for pin, value := range map[int]int{2: 1, 3: 0, 9: 1} {
+		gobottest.Assert(t, a.board.Pins()[pin].Mode, client.Output)
+		gobottest.Assert(t, a.board.Pins()[pin].Value, value)
+	}
+
+	gobottest.Refute(t, a.DigitalWriteMulti([]string{"2", "x"}, 0), nil)
+	gobottest.Assert(t, a.board.Pins()[2].Value, 1)
+}
+
 func TestAdaptorDigitalRead(t *testing.T) {
 	a := initTestAdaptor()
 	val, err := a.DigitalRead("1")
@@ -156,6 +636,96 @@ func TestAdaptorDigitalRead(t *testing.T) {
 	gobottest.Assert(t, val, 1)
 }
 
+func TestAdaptorDigitalReadPullup(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[4].Mode = client.InputPullup
+	a.board.Pins()[4].Value = 1
+	val, err := a.DigitalRead("4")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+	gobottest.Assert(t, a.board.Pins()[4].Mode, client.InputPullup)
+	// the mode is kept, and reporting is enabled for the port of the pin
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).reports, [][3]int{{int(client.ReportDigital), 0, 1}})
+}
+
+func TestAdaptorReadReportIndex(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	a.DigitalRead("10")
+	a.DigitalRead("11")
+	a.AnalogReadWait("2", time.Millisecond)
+	a.AnalogReadWait("2", time.Millisecond)
+	gobottest.Assert(t, *reports, [][3]int{
+		{int(client.ReportDigital), 1, 1},
+		{int(client.ReportAnalog), 2, 1},
+	})
+
+	// reads share the report counts with watchers
+	cancel, err := a.Watch("12", func(int) {})
+	gobottest.Assert(t, err, nil)
+	cancel()
+	gobottest.Assert(t, len(*reports), 2)
+}
+
+func TestAdaptorSetInverted(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.SetInverted("1", true), nil)
+	val, err := a.DigitalRead("1")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 0)
+
+	gobottest.Assert(t, a.DigitalWrite("1", 1), nil)
+	gobottest.Assert(t, a.board.Pins()[1].Value, 0)
+
+	gobottest.Assert(t, a.SetInverted("1", false), nil)
+	val, _ = a.DigitalRead("1")
+	gobottest.Assert(t, val, 0)
+
+	gobottest.Refute(t, a.SetInverted("a", true), nil)
+}
+
+func TestAdaptorDigitalWriteModeError(t *testing.T) {
+	a := initTestAdaptor()
+	board := a.board.(*mockFirmataBoard)
+	board.pins[8].Mode = client.Input
+	fail := true
+	board.setPinMode = func(int, int) error {
+		if fail {
+			return errors.New("write error")
+		}
+		return nil
+	}
+
+	gobottest.Assert(t, a.DigitalWrite("8", 1), errors.New("write error"))
+	gobottest.Assert(t, a.board.Pins()[8].Mode, client.Input)
+
+	// the next write tries the mode change again
+	fail = false
+	before := len(*board.modes)
+	gobottest.Assert(t, a.DigitalWrite("8", 1), nil)
+	gobottest.Assert(t, (*board.modes)[before:], [][2]int{{8, client.Output}})
+	gobottest.Assert(t, a.board.Pins()[8].Value, 1)
+}
+
+func TestAdaptorWithManualModes(t *testing.T) {
+	a := initTestAdaptor()
+	WithManualModes(true)(a)
+	board := a.board.(*mockFirmataBoard)
+	board.pins[8].Mode = client.Input
+	board.pins[9].Mode = client.Input
+	before := len(*board.modes)
+
+	gobottest.Assert(t, a.DigitalWrite("8", 1), nil)
+	gobottest.Assert(t, a.PwmWrite("9", 100), nil)
+	gobottest.Assert(t, a.DigitalWriteMulti([]string{"8"}, 0), nil)
+	gobottest.Assert(t, len(*board.modes), before)
+	gobottest.Assert(t, a.board.Pins()[8].Mode, client.Input)
+
+	WithManualModes(false)(a)
+	gobottest.Assert(t, a.DigitalWrite("8", 1), nil)
+	gobottest.Assert(t, (*board.modes)[before:], [][2]int{{8, client.Output}})
+}
+
 func TestAdaptorAnalogRead(t *testing.T) {
 	a := initTestAdaptor()
 	val, err := a.AnalogRead("1")
@@ -163,25 +733,125 @@ func TestAdaptorAnalogRead(t *testing.T) {
 	gobottest.Assert(t, err, nil)
 }
 
-func TestAdaptorI2cStart(t *testing.T) {
+func TestAdaptorAnalogReadRawPins(t *testing.T) {
 	a := initTestAdaptor()
-	a.I2cStart(0x00)
+	WithRawAnalogPins(true)(a)
+	for i := range a.board.Pins() {
+		a.board.Pins()[i].AnalogChannel = 127
+	}
+	a.board.Pins()[15].AnalogChannel = 1
+
+	val, err := a.AnalogRead("15")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 133)
+	gobottest.Assert(t, a.board.Pins()[15].Mode, client.Analog)
+	reports := *a.board.(*mockFirmataBoard).reports
+	gobottest.Assert(t, reports[len(reports)-1], [3]int{int(client.ReportAnalog), 1, 1})
+
+	_, err = a.AnalogRead("1")
+	gobottest.Assert(t, err, ErrInvalidPin)
+	_, err = a.AnalogRead("100")
+	gobottest.Assert(t, err, ErrInvalidPin)
 }
-func TestAdaptorI2cRead(t *testing.T) {
+
+func TestAdaptorAnalogVoltage(t *testing.T) {
 	a := initTestAdaptor()
-	i := []byte{100}
-	i2cReply := client.I2cReply{Data: i}
+	a.board.Pins()[15].Mode = client.Analog
+	_, err := a.AnalogVoltage("1")
+	gobottest.Assert(t, err, ErrUnknownReference)
+
+	WithAnalogReference(5)(a)
+	volts, err := a.AnalogVoltage("1")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, volts, 133*5/1023.0)
+
+	WithAnalogReference(3.3)(a)
+	a.board.Pins()[15].AnalogResolution = 12
+	volts, _ = a.AnalogVoltage("1")
+	gobottest.Assert(t, volts, 133*3.3/4095)
+
+	_, err = a.AnalogVoltage("x")
+	gobottest.Refute(t, err, nil)
+}
+
+func TestAdaptorAnalogReadWait(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[16].Value = 7
 	go func() {
-		<-time.After(10 * time.Millisecond)
-		a.Publish(a.board.Event("I2cReply"), i2cReply)
+		time.Sleep(10 * time.Millisecond)
+		a.board.Publish("AnalogRead2", 7)
 	}()
-	data, err := a.I2cRead(0x00, 1)
+
+	start := time.Now()
+	val, err := a.AnalogReadWait("2", 5*time.Second)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 7)
+	gobottest.Assert(t, time.Since(start) < time.Second, true)
+
+	start = time.Now()
+	val, err = a.AnalogReadWait("3", 20*time.Millisecond)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 0)
+	gobottest.Assert(t, time.Since(start) >= 20*time.Millisecond, true)
+}
+
+func TestAdaptorSetAnalogInput(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	gobottest.Assert(t, a.SetAnalogInput("2"), nil)
+	gobottest.Assert(t, a.board.Pins()[16].Mode, client.Analog)
+	gobottest.Assert(t, *reports, [][3]int{{int(client.ReportAnalog), 2, 1}})
+
+	// reporting is already enabled, so the read does not wait for a sample
+	a.board.Pins()[16].Value = 9
+	start := time.Now()
+	val, err := a.AnalogReadWait("2", time.Second)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 9)
+	gobottest.Assert(t, time.Since(start) < time.Second, true)
+	gobottest.Assert(t, a.SetAnalogInput("2"), nil)
+	gobottest.Assert(t, len(*reports), 1)
+
+	gobottest.Refute(t, a.SetAnalogInput("x"), nil)
+}
+
+func TestAdaptorReadAllAnalog(t *testing.T) {
+	a := initTestAdaptor()
+	for i := range a.board.Pins() {
+		a.board.Pins()[i].AnalogChannel = 127
+	}
+	a.board.Pins()[14].AnalogChannel = 0
+	a.board.Pins()[15].AnalogChannel = 1
+	a.board.Pins()[14].Mode = client.Analog
+	a.board.Pins()[14].Value = 42
+
+	values, err := a.ReadAllAnalog()
 	gobottest.Assert(t, err, nil)
-	gobottest.Assert(t, data, i)
+	gobottest.Assert(t, values, map[string]int{"0": 42, "1": 133})
 }
-func TestAdaptorI2cWrite(t *testing.T) {
+
+func TestAdaptorReadAllAnalogReleasesOnError(t *testing.T) {
 	a := initTestAdaptor()
-	a.I2cWrite(0x00, []byte{0x00, 0x01})
+	board := a.board.(*mockFirmataBoard)
+	for i := range board.Pins() {
+		board.Pins()[i].AnalogChannel = 127
+	}
+	board.Pins()[14].AnalogChannel = 0
+	board.Pins()[15].AnalogChannel = 1
+	board.setPinMode = func(pin int, mode int) error {
+		if pin == 15 {
+			return errors.New("no analog")
+		}
+		return nil
+	}
+
+	_, err := a.ReadAllAnalog()
+	gobottest.Assert(t, err, errors.New("no analog"))
+	gobottest.Assert(t, *board.reports, [][3]int{
+		{int(client.ReportAnalog), 0, 1},
+		{int(client.ReportAnalog), 0, 0},
+	})
+	gobottest.Assert(t, a.IsReporting("14"), false)
 }
 
 func TestServoConfig(t *testing.T) {
@@ -193,3 +863,18 @@ func TestServoConfig(t *testing.T) {
 	err = a.ServoConfig("a", 0, 0)
 	gobottest.Assert(t, true, strings.Contains(fmt.Sprintf("%v", err), "invalid syntax"))
 }
+
+func TestAdaptorDisconnectEvent(t *testing.T) {
+	a := initTestAdaptor()
+	sem := make(chan interface{}, 1)
+	a.On(a.Event("Disconnect"), func(data interface{}) {
+		sem <- data
+	})
+	a.board.Publish(a.board.Event("Disconnect"), io.EOF)
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, io.EOF)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Disconnect was not published")
+	}
+}
@@ -0,0 +1,51 @@
+package firmata
+
+import (
+	"io"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// NewAdaptorFromBoard returns an Adaptor which adopts board, already connected
+// over conn, such as one released by Detach from an Adaptor being torn down.
+// Connect skips the handshake while board is still connected, so that
+// recreating the Adaptor does not reset the board. The remaining args are
+// those of NewAdaptor, usually the port as a label. The board keeps the
+// configuration of the client it was created with: options configuring the
+// client, such as WithEventBuffer or WithLogger, do not apply to it, while
+// WithEventPrefix must be given again if the board was created with it. The
+// "Health" event counts no bytes until the Adaptor makes a handshake of its
+// own, as an adopted board keeps using the connection it was connected over.
+func NewAdaptorFromBoard(board FirmataBoard, conn io.ReadWriteCloser, args ...interface{}) *Adaptor {
+	f := NewAdaptor(append(args, conn)...)
+	f.board = board
+	f.adopted = true
+	f.dropping = nil
+	f.boardPrefix = ""
+	if _, ok := board.(*client.Client); ok {
+		f.boardPrefix = f.eventPrefix
+	}
+	return f
+}
+
+// Detach releases the board and its connection for NewAdaptorFromBoard,
+// leaving them open. Background work of the Adaptor, such as health checks
+// and reconnecting, is stopped, the handlers it set on the board's events are
+// removed, and writes in progress are waited for. Afterwards Disconnect and
+// Finalize leave the board alone, and the Adaptor must not be used to talk to
+// it any more.
+func (f *Adaptor) Detach() (FirmataBoard, io.ReadWriteCloser) {
+	f.stopReconnect()
+	f.stopHealth()
+	if err := f.drainWrites(); err != nil {
+		f.logger.Errorf("firmata: %v", err)
+	}
+	f.releaseBoardEvents()
+	f.detached = true
+
+	conn := f.conn
+	if c, ok := conn.(*countingConn); ok {
+		conn = c.ReadWriteCloser
+	}
+	return f.board, conn
+}
@@ -0,0 +1,76 @@
+package firmata
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorDetach(t *testing.T) {
+	a := initTestAdaptor()
+	board := a.board.(*mockFirmataBoard)
+	board.disconnectError = errors.New("disconnected")
+
+	b, conn := a.Detach()
+	gobottest.Assert(t, b, FirmataBoard(board))
+	gobottest.Refute(t, conn, nil)
+	gobottest.Assert(t, a.Finalize(), nil)
+	gobottest.Assert(t, a.Disconnect(), nil)
+}
+
+func TestAdaptorDetachReleasesBoard(t *testing.T) {
+	a := initTestAdaptor()
+	feature := testSysexFeature{data: make(chan []byte, 1)}
+	gobottest.Assert(t, a.RegisterSysexFeature(feature), nil)
+
+	board, conn := a.Detach()
+	_, wrapped := conn.(*countingConn)
+	gobottest.Assert(t, wrapped, false)
+
+	// the detached Adaptor no longer handles the events of the board
+	b := NewAdaptorFromBoard(board, conn, "/dev/null")
+	gobottest.Assert(t, b.Connect(), nil)
+	board.Publish(board.Event("Error"), errors.New("read error"))
+	board.Publish(board.Event("SysexResponse"), []byte{0xF0, 0x51, 1, 2, 0xF7})
+	for b.LastError() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	gobottest.Assert(t, a.LastError(), nil)
+	gobottest.Assert(t, len(feature.data), 0)
+}
+
+func TestNewAdaptorFromBoard(t *testing.T) {
+	a := initTestAdaptor()
+	board, conn := a.Detach()
+	handshakes := 0
+	board.(*mockFirmataBoard).connect = func() error {
+		handshakes++
+		return nil
+	}
+
+	b := NewAdaptorFromBoard(board, conn, "/dev/null")
+	gobottest.Assert(t, b.Port(), "/dev/null")
+	gobottest.Assert(t, b.board, board)
+	gobottest.Assert(t, b.Connect(), nil)
+	gobottest.Assert(t, handshakes, 0)
+	gobottest.Assert(t, b.DigitalWrite("2", 1), nil)
+	gobottest.Assert(t, board.Pins()[2].Value, 1)
+
+	// a board which is no longer connected gets a new handshake
+	board.(*mockFirmataBoard).disconnected = true
+	gobottest.Assert(t, b.Connect(), nil)
+	gobottest.Assert(t, handshakes, 1)
+}
+
+func TestNewAdaptorFromBoardEventPrefix(t *testing.T) {
+	c := client.New()
+	a := NewAdaptorFromBoard(c, &readWriteCloser{}, WithEventPrefix("left:"))
+	gobottest.Assert(t, a.board, FirmataBoard(c))
+	gobottest.Assert(t, a.boardEvent("AnalogRead0"), "left:AnalogRead0")
+
+	a = NewAdaptorFromBoard(newMockFirmataBoard(), &readWriteCloser{}, WithEventPrefix("left:"))
+	gobottest.Assert(t, a.boardEvent("AnalogRead0"), "AnalogRead0")
+}
@@ -0,0 +1,31 @@
+package firmata
+
+// batchWriter is implemented by boards which can hold the messages they write
+// and write them at once, such as client.Client.
+type batchWriter interface {
+	BeginBatch()
+	EndBatch() error
+}
+
+// Batch calls fn and writes the messages it makes, such as those of
+// SetPinMode, DigitalWrite or ServoConfig, to the connection in a single
+// write when it returns, in the order they were made. This saves a write call
+// per message when configuring many pins at startup. Messages written by
+// other goroutines while fn runs are held as well. As nothing reaches the
+// board before fn returns, fn must not wait for an answer from it, as the
+// reads do, or changes of mode do with WithModeRetries. Returns the error of
+// the final write. Boards which cannot batch their writes simply run fn.
+func (f *Adaptor) Batch(fn func()) (err error) {
+	b, ok := f.board.(batchWriter)
+	if !ok {
+		fn()
+		return nil
+	}
+
+	b.BeginBatch()
+	defer func() {
+		err = b.EndBatch()
+	}()
+	fn()
+	return nil
+}
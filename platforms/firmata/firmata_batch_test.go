@@ -0,0 +1,54 @@
+package firmata
+
+import (
+	"errors"
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+type batchingBoard struct {
+	*mockFirmataBoard
+	calls *[]string
+	err   error
+}
+
+func (b batchingBoard) BeginBatch() { *b.calls = append(*b.calls, "begin") }
+func (b batchingBoard) EndBatch() error {
+	*b.calls = append(*b.calls, "end")
+	return b.err
+}
+func (b batchingBoard) WriteSysex(data []byte) error {
+	*b.calls = append(*b.calls, "sysex")
+	return b.mockFirmataBoard.WriteSysex(data)
+}
+func (b batchingBoard) SetPinMode(pin int, mode int) error {
+	*b.calls = append(*b.calls, "mode")
+	return b.mockFirmataBoard.SetPinMode(pin, mode)
+}
+
+func TestAdaptorBatch(t *testing.T) {
+	a := initTestAdaptor()
+	calls := []string{}
+	board := batchingBoard{mockFirmataBoard: a.board.(*mockFirmataBoard), calls: &calls}
+	a.board = board
+
+	err := a.Batch(func() {
+		a.DigitalWrite("2", 1)
+		a.DigitalWrite("3", 1)
+	})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, calls, []string{"begin", "mode", "mode", "end"})
+	gobottest.Assert(t, a.board.Pins()[3].Mode, client.Output)
+
+	board.err = errors.New("write error")
+	a.board = board
+	gobottest.Assert(t, a.Batch(func() {}), errors.New("write error"))
+
+	// boards which cannot batch run fn as it is
+	a = initTestAdaptor()
+	ran := false
+	gobottest.Assert(t, a.Batch(func() { ran = true }), nil)
+	gobottest.Assert(t, ran, true)
+}
@@ -0,0 +1,73 @@
+package firmata
+
+import "errors"
+
+// DefaultBaudRate is the baud rate the Adaptor opens serial ports at, as used
+// by StandardFirmata.
+const DefaultBaudRate = 57600
+
+// DefaultBaudRates are the baud rates WithAutoBaud tries when given none.
+var DefaultBaudRates = []int{57600, 115200, 9600, 19200, 38400}
+
+// Errors
+var (
+	ErrBaudNotFound = errors.New("board did not complete the handshake at any of the baud rates tried")
+)
+
+// WithAutoBaud makes Connect find the baud rate of the firmware by opening
+// the serial port at each of rates in turn, or at DefaultBaudRates if none are
+// given, until the board completes the handshake. Each rate is given the
+// handshake timeout, see WithHandshakeTimeout, so a shorter one speeds up the
+// search. The rate found is tried first by later connects and reported by
+// BaudRate. Connections passed to NewAdaptor are used as they are.
+func WithAutoBaud(rates ...int) Option {
+	return func(f *Adaptor) {
+		if len(rates) == 0 {
+			rates = DefaultBaudRates
+		}
+		f.baudRates = append([]int(nil), rates...)
+	}
+}
+
+// BaudRate returns the baud rate the Adaptor opens the serial port at. With
+// WithAutoBaud it is the rate found by the last successful Connect.
+func (f *Adaptor) BaudRate() int {
+	return f.baud
+}
+
+// connectAutoBaud opens the port and connects the board at each rate set with
+// WithAutoBaud until the handshake completes, and moves the rate found to the
+// front so that it is tried first next time.
+func (f *Adaptor) connectAutoBaud() error {
+	for i, baud := range f.baudRates {
+		f.baud = baud
+		sp, err := f.openCommPort(f.port)
+		if err != nil {
+			return err
+		}
+		f.conn = sp
+		f.openedConn = true
+		if err = f.setupFlowControl(); err != nil {
+			f.conn.Close()
+			f.conn = nil
+			f.openedConn = false
+			return err
+		}
+		f.conn = f.health.wrap(f.conn)
+
+		err = f.connectBoard()
+		if err == nil {
+			copy(f.baudRates[1:i+1], f.baudRates[:i])
+			f.baudRates[0] = baud
+			f.logger.Infof("firmata: %v answered at %v baud", f.Label(), baud)
+			return nil
+		}
+		f.logger.Debugf("firmata: no handshake from %v at %v baud: %v", f.Label(), baud, err)
+		if f.conn != nil {
+			f.conn.Close()
+			f.conn = nil
+			f.openedConn = false
+		}
+	}
+	return ErrBaudNotFound
+}
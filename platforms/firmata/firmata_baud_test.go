@@ -0,0 +1,65 @@
+package firmata
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorAutoBaud(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithAutoBaud(57600, 9600, 115200),
+		WithHandshakeTimeout(10*time.Millisecond))
+	gobottest.Assert(t, a.BaudRate(), DefaultBaudRate)
+
+	opened := []int{}
+	closed := make(chan bool, 3)
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		opened = append(opened, a.baud)
+		return &closeNotifier{closed: closed}, nil
+	}
+	board := newMockFirmataBoard()
+	board.connect = func() error {
+		if a.baud != 115200 {
+			return errors.New("garbled reply")
+		}
+		return nil
+	}
+	a.board = board
+
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, opened, []int{57600, 9600, 115200})
+	gobottest.Assert(t, len(closed), 2)
+	gobottest.Assert(t, a.BaudRate(), 115200)
+
+	// the rate found is tried first on the next connect
+	opened = opened[:0]
+	a.conn = nil
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, opened, []int{115200})
+}
+
+func TestAdaptorAutoBaudNotFound(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithAutoBaud(), WithHandshakeTimeout(10*time.Millisecond))
+	closed := make(chan bool, len(DefaultBaudRates))
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		return &closeNotifier{closed: closed}, nil
+	}
+	board := newMockFirmataBoard()
+	board.connect = func() error {
+		return errors.New("garbled reply")
+	}
+	a.board = board
+
+	gobottest.Assert(t, a.Connect(), ErrBaudNotFound)
+	gobottest.Assert(t, len(closed), len(DefaultBaudRates))
+	gobottest.Assert(t, a.conn, nil)
+
+	a = NewAdaptor("/dev/null", WithAutoBaud())
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		return nil, errors.New("no such port")
+	}
+	gobottest.Assert(t, a.Connect(), errors.New("no such port"))
+}
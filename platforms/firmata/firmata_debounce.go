@@ -0,0 +1,175 @@
+package firmata
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// SetDebounce makes the pin report a new value only once the line has been
+// steady for d. DigitalRead returns the debounced value, and Watch on the pin
+// is called with debounced changes. SetDebounce should be called before
+// watching the pin, as existing watchers keep receiving raw values. A zero
+// duration disables debouncing for the pin.
+func (f *Adaptor) SetDebounce(pin string, d time.Duration) error {
+	f.pinMutex.Lock()
+	p, err := f.resolvePin(pin)
+	if err != nil {
+		f.pinMutex.Unlock()
+		return err
+	}
+	old := f.debouncers[p]
+	delete(f.debouncers, p)
+	f.pinMutex.Unlock()
+
+	if old != nil {
+		old.stop()
+		old.release()
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	if _, err = f.ensureMode(p, client.Input); err != nil {
+		return err
+	}
+
+	events := f.board.Subscribe()
+	release, err := f.acquireReport(digitalReport, p/8)
+	if err != nil {
+		unsubscribe(f.board, events)
+		return err
+	}
+
+	state, _ := f.pin(p)
+	db := newDebouncer(f.board, events, f.boardEvent(fmt.Sprintf("DigitalRead%v", p)), d, state.Value)
+	db.release = release
+	f.pinMutex.Lock()
+	f.debouncers[p] = db
+	f.pinMutex.Unlock()
+	return nil
+}
+
+func (f *Adaptor) debouncer(pin int) *debouncer {
+	f.pinMutex.RLock()
+	defer f.pinMutex.RUnlock()
+	return f.debouncers[pin]
+}
+
+// debouncer follows the digital reports of a pin and settles on a value once
+// no change has been seen for delay.
+type debouncer struct {
+	mutex   sync.Mutex
+	delay   time.Duration
+	stable  int
+	pending int
+	timer   *time.Timer
+	closed  bool
+
+	notify   sync.Mutex
+	handlers map[int]func(int)
+	next     int
+
+	board   gobot.Eventer
+	events  chan *gobot.Event
+	release func() error
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newDebouncer(board gobot.Eventer, events chan *gobot.Event, name string, delay time.Duration, value int) *debouncer {
+	d := &debouncer{
+		delay:    delay,
+		stable:   value,
+		pending:  value,
+		handlers: make(map[int]func(int)),
+		board:    board,
+		events:   events,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(d.stopped)
+		for {
+			select {
+			case evt := <-events:
+				if value, ok := evt.Data.(int); ok && evt.Name == name {
+					d.update(value)
+				}
+			case <-d.done:
+				return
+			}
+		}
+	}()
+	return d
+}
+
+func (d *debouncer) update(value int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+	d.pending = value
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.delay, d.settle)
+	} else {
+		d.timer.Reset(d.delay)
+	}
+}
+
+func (d *debouncer) settle() {
+	d.notify.Lock()
+	defer d.notify.Unlock()
+
+	d.mutex.Lock()
+	changed := !d.closed && d.pending != d.stable
+	d.stable = d.pending
+	value := d.stable
+	d.mutex.Unlock()
+
+	if changed {
+		for _, handler := range d.handlers {
+			handler(value)
+		}
+	}
+}
+
+func (d *debouncer) value() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.stable
+}
+
+// watch registers handler for debounced changes. The returned function
+// removes it, waiting for a running call to return.
+func (d *debouncer) watch(handler func(int)) func() {
+	d.notify.Lock()
+	id := d.next
+	d.next++
+	d.handlers[id] = handler
+	d.notify.Unlock()
+
+	return func() {
+		d.notify.Lock()
+		delete(d.handlers, id)
+		d.notify.Unlock()
+	}
+}
+
+func (d *debouncer) stop() {
+	close(d.done)
+	<-d.stopped
+	unsubscribe(d.board, d.events)
+
+	d.mutex.Lock()
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.mutex.Unlock()
+}
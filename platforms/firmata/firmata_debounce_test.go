@@ -0,0 +1,50 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorSetDebounce(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	gobottest.Assert(t, a.SetDebounce("10", 20*time.Millisecond), nil)
+	gobottest.Assert(t, a.board.Pins()[10].Mode, client.Input)
+	gobottest.Assert(t, *reports, [][3]int{{int(client.ReportDigital), 1, 1}})
+
+	values := make(chan int, 10)
+	cancel, err := a.Watch("10", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+
+	for _, v := range []int{1, 0, 1, 0, 1} {
+		a.board.Publish("DigitalRead10", v)
+	}
+	val, _ := a.DigitalRead("10")
+	gobottest.Assert(t, val, 0)
+
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 1)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+	val, _ = a.DigitalRead("10")
+	gobottest.Assert(t, val, 1)
+	select {
+	case v := <-values:
+		t.Fatalf("unexpected value %v", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	gobottest.Assert(t, a.SetDebounce("10", 0), nil)
+	gobottest.Assert(t, (*reports)[1], [3]int{int(client.ReportDigital), 1, 0})
+}
+
+func TestAdaptorSetDebounceInvalidPin(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.SetDebounce("x", time.Millisecond), ErrInvalidPin)
+}
@@ -0,0 +1,133 @@
+package firmata
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gobot.io/x/gobot"
+)
+
+// Stats holds counters of the Adaptor's event delivery.
+type Stats struct {
+	// EventsDropped is the number of board events discarded because the
+	// event queue was full. It only grows when WithDropEvents is set.
+	EventsDropped uint64
+}
+
+// WithDropEvents sets whether the client the Adaptor creates for the board
+// discards analog and digital reports instead of blocking its read loop when
+// subscribers fall behind. Board events are queued, up to the size set by
+// WithEventBuffer or a single event without it, and a new report is dropped
+// when the queue is full, so that those already queued are delivered in
+// order. Other events, such as the handshake replies, "I2cReply", "PinState"
+// and "Disconnect", are never dropped and wait for room in the queue. Dropped
+// reports are counted in Stats. Waits for a report, such as AnalogRead, may
+// time out if theirs is dropped. The queue is delivered until Finalize.
+func WithDropEvents(drop bool) Option {
+	return func(f *Adaptor) {
+		f.dropEvents = drop
+	}
+}
+
+// Stats returns the counters of the Adaptor's event delivery.
+func (f *Adaptor) Stats() Stats {
+	var stats Stats
+	if f.dropping != nil {
+		stats.EventsDropped = atomic.LoadUint64(&f.dropping.dropped)
+	}
+	return stats
+}
+
+// droppingEventer is a gobot.Eventer which publishes through a queue, and
+// discards pin reports published while the queue is full instead of blocking.
+type droppingEventer struct {
+	dropped uint64
+	queue   chan *gobot.Event
+	prefix  string
+	done    chan struct{}
+	once    sync.Once
+	gobot.Eventer
+}
+
+// newDroppingEventer returns eventer wrapped so that publishing a report
+// never blocks, with a queue of size events. The events are published under
+// names starting with prefix.
+func newDroppingEventer(eventer gobot.Eventer, size int, prefix string) *droppingEventer {
+	if size < 1 {
+		size = 1
+	}
+	e := &droppingEventer{
+		Eventer: eventer,
+		queue:   make(chan *gobot.Event, size),
+		prefix:  prefix,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			select {
+			case evt := <-e.queue:
+				if !e.stopped() {
+					e.Eventer.Publish(evt.Name, evt.Data)
+				}
+			case <-e.done:
+				return
+			}
+		}
+	}()
+	return e
+}
+
+// Publish queues the event for delivery. A report is dropped if the queue is
+// full, while any other event waits for room. Once stopped, events are
+// discarded.
+func (e *droppingEventer) Publish(name string, data interface{}) {
+	if e.stopped() {
+		return
+	}
+	evt := gobot.NewEvent(name, data)
+	if !isReportEvent(strings.TrimPrefix(name, e.prefix)) {
+		select {
+		case e.queue <- evt:
+		case <-e.done:
+		}
+		return
+	}
+	select {
+	case e.queue <- evt:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// stopped reports whether stop was called.
+func (e *droppingEventer) stopped() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop ends the delivery of the queue. It may be called more than once.
+func (e *droppingEventer) stop() {
+	e.once.Do(func() {
+		close(e.done)
+	})
+}
+
+// isReportEvent reports whether name is that of an analog or digital report,
+// such as "AnalogRead0" or "DigitalRead13".
+func isReportEvent(name string) bool {
+	for _, prefix := range []string{"AnalogRead", "DigitalRead"} {
+		if strings.HasPrefix(name, prefix) {
+			if _, err := strconv.Atoi(name[len(prefix):]); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
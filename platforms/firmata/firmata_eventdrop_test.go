@@ -0,0 +1,102 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorDropEvents(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithDropEvents(true))
+	gobottest.Assert(t, a.Stats().EventsDropped, uint64(0))
+
+	// a subscriber which never reads stalls delivery
+	a.board.Subscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			a.board.Publish("AnalogRead0", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("publishing blocked")
+	}
+	gobottest.Assert(t, a.Stats().EventsDropped >= 6, true)
+}
+
+func TestAdaptorDropEventsKeepsOtherEvents(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithDropEvents(true), WithEventPrefix("left."))
+	events := a.board.Subscribe()
+
+	// reports are dropped while the queue is full, replies wait for room
+	done := make(chan struct{})
+	go func() {
+		a.board.Publish(a.board.Event("I2cReply"), 1)
+		a.board.Publish("left.AnalogRead0", 2)
+		a.board.Publish("left.AnalogRead0", 3)
+		a.board.Publish("left.AnalogRead0", 4)
+		a.board.Publish(a.board.Event("Disconnect"), 5)
+		close(done)
+	}()
+
+	var received []interface{}
+	for len(received) < 2 || received[len(received)-1] != 5 {
+		select {
+		case evt := <-events:
+			received = append(received, evt.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("events were not delivered, got %v", received)
+		}
+	}
+	<-done
+	gobottest.Assert(t, received[0], 1)
+	gobottest.Assert(t, uint64(len(received))+a.Stats().EventsDropped, uint64(5))
+}
+
+func TestAdaptorDropEventsFinalize(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithDropEvents(true))
+	a.Finalize()
+	events := a.board.Subscribe()
+
+	// the queue is no longer delivered, and publishing does not block on it
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			a.board.Publish(a.board.Event("I2cReply"), i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("publishing blocked")
+	}
+	select {
+	case evt := <-events:
+		t.Fatalf("event %v was delivered", evt.Name)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestAdaptorWithoutDropEvents(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithDropEvents(true), WithDropEvents(false))
+	gobottest.Assert(t, a.dropping, (*droppingEventer)(nil))
+}
+
+func TestIsReportEvent(t *testing.T) {
+	gobottest.Assert(t, isReportEvent("AnalogRead0"), true)
+	gobottest.Assert(t, isReportEvent("DigitalRead13"), true)
+	gobottest.Assert(t, isReportEvent("I2cReply"), false)
+	gobottest.Assert(t, isReportEvent("PinState2"), false)
+	gobottest.Assert(t, isReportEvent("AnalogReadings"), false)
+}
+
+func TestAdaptorStatsWithoutDropEvents(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.Stats(), Stats{})
+}
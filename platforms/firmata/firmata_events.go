@@ -0,0 +1,213 @@
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Errors
+var (
+	ErrReservedEvent = errors.New("event name is used by the adaptor or the board")
+)
+
+// builtinEvents are the events published by the Adaptor or its board.
+var builtinEvents = []string{
+	"Health", "Firmware", "Disconnect", "ReportingRestored", "Reconnect",
+	"Warning", "SerialRead", "Error", "FirmwareQuery", "CapabilityQuery",
+	"AnalogMappingQuery", "ProtocolVersion", "I2cReply", "SerialReply",
+	"StringData", "SysexResponse", "RawSysex",
+}
+
+// builtinEventPrefixes are the per pin events of the board, which are named
+// by a prefix followed by the pin or channel number.
+var builtinEventPrefixes = []string{"AnalogRead", "DigitalRead", "PinState"}
+
+// PublishEvent publishes data with the user-defined event name on the
+// Adaptor's Eventer, adding the event first if needed. This lets code derive
+// its own events, such as "ThresholdCrossed", and deliver them to the same
+// subscribers as the built-in ones. Returns ErrReservedEvent for the name of
+// an event published by the Adaptor or the board, such as "I2cReply" or
+// "AnalogRead0".
+func (f *Adaptor) PublishEvent(name string, data interface{}) error {
+	if isBuiltinEvent(name) {
+		return ErrReservedEvent
+	}
+	if _, ok := f.Events()[name]; !ok {
+		f.AddEvent(name)
+	}
+	f.Publish(f.Event(name), data)
+	return nil
+}
+
+// OnI2cReply calls handler with every I2C reply from the board, until the
+// returned function is called. The handler runs as in OnEvent, so it may
+// itself read from the board, and is not called after that function returns,
+// which must not be called from within the handler.
+func (f *Adaptor) OnI2cReply(handler func(client.I2cReply)) (cancel func()) {
+	return f.OnBoardEvent("I2cReply", func(data interface{}) {
+		if reply, ok := data.(client.I2cReply); ok {
+			handler(reply)
+		}
+	})
+}
+
+// OnI2cData calls handler with the data of every I2C reply from the device at
+// address, until the returned function is called, so that each device on a
+// shared bus streaming replies has its replies routed to its own handler.
+// Replies from other devices are ignored. The handler runs as in OnI2cReply.
+func (f *Adaptor) OnI2cData(address int, handler func(data []byte)) (cancel func()) {
+	address &^= client.I2cTenBit | client.I2cRestart
+	return f.OnI2cReply(func(reply client.I2cReply) {
+		if reply.Address == address {
+			handler(reply.Data)
+		}
+	})
+}
+
+// OnAnalogRead calls handler with every analog report for the channel, named
+// as in AnalogRead, until the returned function is called. Reporting is not
+// enabled by OnAnalogRead; see AnalogRead and Watch.
+func (f *Adaptor) OnAnalogRead(channel string, handler func(value int)) (cancel func(), err error) {
+	c, err := strconv.Atoi(channel)
+	if err != nil {
+		return nil, err
+	}
+	return f.OnBoardEvent(fmt.Sprintf("AnalogRead%v", c), onInt(handler)), nil
+}
+
+// OnDigitalRead calls handler with every digital report for the pin, named as
+// in Pin, until the returned function is called. Reporting is not enabled by
+// OnDigitalRead; see DigitalRead and Watch.
+func (f *Adaptor) OnDigitalRead(pin string, handler func(value int)) (cancel func(), err error) {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(pin)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return f.OnBoardEvent(fmt.Sprintf("DigitalRead%v", p), onInt(handler)), nil
+}
+
+func onInt(handler func(int)) func(interface{}) {
+	return func(data interface{}) {
+		if value, ok := data.(int); ok {
+			handler(value)
+		}
+	}
+}
+
+// OnEvent is like On, for the events of the Adaptor such as "Health" or those
+// published with PublishEvent, but returns a function which removes the
+// handler. The handler runs on its own goroutine, with events arriving
+// meanwhile queued, so it may subscribe to events or read pins itself. The
+// returned function may be called more than once, waits for a running handler
+// to return, and must not be called from within the handler.
+func (f *Adaptor) OnEvent(name string, handler func(data interface{})) (cancel func()) {
+	return handleEvent(f, f.eventPrefix+name, handler)
+}
+
+// OnBoardEvent is like OnEvent, for the events of the board such as
+// "I2cReply" or "DigitalRead2".
+func (f *Adaptor) OnBoardEvent(name string, handler func(data interface{})) (cancel func()) {
+	return handleEvent(f.board, f.boardEvent(name), handler)
+}
+
+// handleEvent calls handler with the data of each name event of eventer
+// until the returned function is called.
+func handleEvent(eventer gobot.Eventer, name string, handler func(interface{})) (cancel func()) {
+	events := eventer.Subscribe()
+	queue := newHandlerQueue(handler)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case evt := <-events:
+				if evt.Name == name {
+					queue.push(evt.Data)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+			unsubscribe(eventer, events)
+			queue.close()
+		})
+	}
+}
+
+// WithEventPrefix makes the Adaptor, and the client it creates for the board,
+// publish their events with names starting with prefix, such as
+// "left.I2cReply" for a prefix of "left.", so that the events of several
+// boards forwarded to one Eventer can be told apart. Event and Events return
+// the prefixed names, which are the ones to pass to On, while the methods
+// taking an event name, such as OnEvent, OnBoardEvent and PublishEvent, take
+// it without the prefix.
+func WithEventPrefix(prefix string) Option {
+	return func(f *Adaptor) {
+		f.eventPrefix = prefix
+	}
+}
+
+// prefixEventer is a gobot.Eventer which publishes its events under names
+// starting with a prefix.
+type prefixEventer struct {
+	gobot.Eventer
+	prefix string
+}
+
+// Events returns the registered event names, mapped to the prefixed names
+// they are published under.
+func (e *prefixEventer) Events() map[string]string {
+	names := make(map[string]string)
+	for name, event := range e.Eventer.Events() {
+		names[name] = e.prefix + event
+	}
+	return names
+}
+
+// Event returns the prefixed name of the registered event name, or "" if no
+// such event is registered.
+func (e *prefixEventer) Event(name string) string {
+	if event := e.Eventer.Event(name); event != "" {
+		return e.prefix + event
+	}
+	return ""
+}
+
+// boardEvent returns the name the board publishes the event name under, with
+// the prefix set with WithEventPrefix if the client created by the Adaptor
+// carries it.
+func (f *Adaptor) boardEvent(name string) string {
+	return f.boardPrefix + name
+}
+
+func isBuiltinEvent(name string) bool {
+	for _, event := range builtinEvents {
+		if name == event {
+			return true
+		}
+	}
+	for _, prefix := range builtinEventPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			if _, err := strconv.Atoi(name[len(prefix):]); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorPublishEvent(t *testing.T) {
+	a := initTestAdaptor()
+	sem := make(chan interface{}, 1)
+	a.On("ThresholdCrossed", func(data interface{}) {
+		sem <- data
+	})
+
+	gobottest.Assert(t, a.PublishEvent("ThresholdCrossed", 512), nil)
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, 512)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("ThresholdCrossed was not published")
+	}
+	gobottest.Assert(t, a.Event("ThresholdCrossed"), "ThresholdCrossed")
+
+	for _, name := range []string{"I2cReply", "Health", "AnalogRead0", "DigitalRead13", "PinState2"} {
+		gobottest.Assert(t, a.PublishEvent(name, nil), ErrReservedEvent)
+	}
+	gobottest.Assert(t, a.PublishEvent("DigitalReadings", nil), nil)
+}
+
+func TestAdaptorOnI2cReply(t *testing.T) {
+	a := initTestAdaptor()
+	replies := make(chan client.I2cReply, 1)
+	cancel := a.OnI2cReply(func(reply client.I2cReply) { replies <- reply })
+
+	a.board.Publish("I2cReply", "not a reply")
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x40, Data: []byte{1}})
+	select {
+	case reply := <-replies:
+		gobottest.Assert(t, reply.Address, 0x40)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+
+	cancel()
+	cancel()
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x41})
+	gobottest.Assert(t, len(replies), 0)
+}
+
+func TestAdaptorOnI2cReplyHandlerReads(t *testing.T) {
+	a := initTestAdaptor()
+	values := make(chan int, 1)
+	cancel := a.OnI2cReply(func(reply client.I2cReply) {
+		value, _ := a.AnalogRead("1")
+		values <- value
+	})
+	defer cancel()
+
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x40, Data: []byte{1}})
+	select {
+	case value := <-values:
+		gobottest.Assert(t, value, 133)
+	case <-time.After(time.Second):
+		t.Fatalf("handler was not called")
+	}
+}
+
+func TestAdaptorOnI2cData(t *testing.T) {
+	a := initTestAdaptor()
+	first := make(chan []byte, 2)
+	second := make(chan []byte, 2)
+	cancelFirst := a.OnI2cData(0x40, func(data []byte) { first <- data })
+	cancelSecond := a.OnI2cData(0x41, func(data []byte) { second <- data })
+	defer cancelSecond()
+
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x41, Data: []byte{2}})
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x40, Data: []byte{1}})
+	for _, c := range []struct {
+		replies chan []byte
+		data    []byte
+	}{{first, []byte{1}}, {second, []byte{2}}} {
+		select {
+		case data := <-c.replies:
+			gobottest.Assert(t, data, c.data)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("handler was not called")
+		}
+	}
+
+	cancelFirst()
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x40, Data: []byte{3}})
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x41, Data: []byte{4}})
+	select {
+	case data := <-second:
+		gobottest.Assert(t, data, []byte{4})
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+	gobottest.Assert(t, len(first), 0)
+	gobottest.Assert(t, len(second), 0)
+}
+
+func TestAdaptorOnAnalogRead(t *testing.T) {
+	a := initTestAdaptor()
+	values := make(chan int, 1)
+	cancel, err := a.OnAnalogRead("1", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("AnalogRead0", 1)
+	a.board.Publish("AnalogRead1", 512)
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 512)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+
+	_, err = a.OnAnalogRead("x", func(int) {})
+	gobottest.Refute(t, err, nil)
+}
+
+func TestAdaptorOnDigitalRead(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.SetPinAlias("button", "7"), nil)
+	values := make(chan int, 1)
+	cancel, err := a.OnDigitalRead("button", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("DigitalRead7", 1)
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 1)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+
+	_, err = a.OnDigitalRead("nope", func(int) {})
+	gobottest.Assert(t, err, ErrInvalidPin)
+}
+
+func TestAdaptorOnEvent(t *testing.T) {
+	a := initTestAdaptor()
+	sem := make(chan interface{}, 2)
+	cancel := a.OnEvent("ThresholdCrossed", func(data interface{}) { sem <- data })
+	a.PublishEvent("ThresholdCrossed", 1)
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, 1)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+
+	cancel()
+	cancel()
+	a.PublishEvent("ThresholdCrossed", 2)
+	gobottest.Assert(t, len(sem), 0)
+}
+
+func TestAdaptorOnBoardEvent(t *testing.T) {
+	a := initTestAdaptor()
+	sem := make(chan interface{}, 2)
+	cancel := a.OnBoardEvent("StringData", func(data interface{}) { sem <- data })
+	a.board.Publish("StringData", "hello")
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, "hello")
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+
+	cancel()
+	a.board.Publish("StringData", "again")
+	gobottest.Assert(t, len(sem), 0)
+}
+
+func TestAdaptorOnBoardEventHandlerSubscribes(t *testing.T) {
+	a := initTestAdaptor()
+	sem := make(chan interface{}, 2)
+	cancel := a.OnBoardEvent("StringData", func(data interface{}) {
+		a.OnBoardEvent("Error", func(interface{}) {})()
+		sem <- data
+	})
+	defer cancel()
+
+	a.board.Publish("StringData", "one")
+	a.board.Publish("StringData", "two")
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case data := <-sem:
+			gobottest.Assert(t, data, expected)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("handler was not called")
+		}
+	}
+}
+
+func TestAdaptorWithEventPrefix(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithEventPrefix("left."))
+	gobottest.Assert(t, a.Event("Health"), "left.Health")
+	gobottest.Assert(t, a.Events()["Firmware"], "left.Firmware")
+	gobottest.Assert(t, a.board.Event("I2cReply"), "left.I2cReply")
+	gobottest.Assert(t, a.board.Event("Unknown"), "")
+
+	replies := make(chan client.I2cReply, 1)
+	cancel := a.OnI2cReply(func(reply client.I2cReply) {
+		replies <- reply
+	})
+	defer cancel()
+	a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x10})
+	select {
+	case reply := <-replies:
+		gobottest.Assert(t, reply.Address, 0x10)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("I2cReply was not received")
+	}
+
+	events := make(chan string, 1)
+	cancel = a.OnEvent("Custom", func(interface{}) {
+		events <- "Custom"
+	})
+	defer cancel()
+	gobottest.Assert(t, a.PublishEvent("Custom", nil), nil)
+	select {
+	case <-events:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Custom was not received")
+	}
+	gobottest.Assert(t, a.Event("Custom"), "left.Custom")
+}
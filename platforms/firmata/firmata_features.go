@@ -0,0 +1,142 @@
+package firmata
+
+import (
+	"errors"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Errors
+var (
+	ErrNotSupported      = errors.New("feature is not supported by the firmware")
+	ErrNotConnected      = errors.New("board is not connected")
+	ErrCapabilityTimeout = errors.New("timed out waiting for the capabilities of the board")
+)
+
+// featureModes maps the features known to SupportsFeature to the pin mode
+// which the firmware reports for them.
+var featureModes = map[string]int{
+	"input":   client.Input,
+	"output":  client.Output,
+	"analog":  client.Analog,
+	"pwm":     client.Pwm,
+	"servo":   client.Servo,
+	"shift":   client.Shift,
+	"i2c":     client.I2c,
+	"onewire": client.OneWire,
+	"stepper": client.Stepper,
+	"encoder": client.Encoder,
+	"serial":  client.Serial,
+	"pullup":  client.InputPullup,
+	"spi":     client.Spi,
+	"sonar":   client.Sonar,
+	"tone":    client.Tone,
+	"dht":     client.Dht,
+}
+
+// SupportsFeature reports whether the capability response of the board lists
+// the pin mode of feature, such as "i2c", "servo" or "tone", for at least one
+// pin. Firmata has no separate report of the features a firmware implements,
+// so only features with a pin mode, those named in featureModes, can be
+// queried; others are reported as unsupported.
+func (f *Adaptor) SupportsFeature(feature string) bool {
+	mode, ok := featureModes[feature]
+	if !ok {
+		return false
+	}
+	for _, pin := range f.board.Pins() {
+		if supportsMode(pin, mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsMode reports whether the capability response lists mode for pin.
+func supportsMode(pin client.Pin, mode int) bool {
+	for _, m := range pin.SupportedModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// requireFeature returns ErrNotSupported if the capability response shows
+// that the firmware lacks feature.
+func (f *Adaptor) requireFeature(feature string) error {
+	mode, ok := featureModes[feature]
+	if !ok {
+		return ErrNotSupported
+	}
+	return f.requireMode(mode)
+}
+
+// requireMode returns ErrNotSupported if no pin supports mode. Boards which
+// have not reported their capabilities are not refused anything.
+func (f *Adaptor) requireMode(mode int) error {
+	known := false
+	for _, pin := range f.board.Pins() {
+		if supportsMode(pin, mode) {
+			return nil
+		}
+		known = known || len(pin.SupportedModes) > 0
+	}
+	if known {
+		return ErrNotSupported
+	}
+	return nil
+}
+
+// RefreshCapabilities queries the capabilities and analog mapping of the board
+// again, as Connect does, and replaces the pin data with the answers. This
+// picks up a firmware flashed without reconnecting. Pins which are still
+// there keep their mode and value if they still support the mode, while the
+// others start out in output mode with no value. Each answer is waited for up
+// to the handshake timeout, after which ErrCapabilityTimeout is returned.
+// Returns ErrNotConnected if the board is not connected.
+func (f *Adaptor) RefreshCapabilities() error {
+	if !f.board.Connected() {
+		return ErrNotConnected
+	}
+
+	events := f.board.Subscribe()
+	defer unsubscribe(f.board, events)
+
+	for _, query := range []struct {
+		command byte
+		event   string
+	}{
+		{client.CapabilityQuery, "CapabilityQuery"},
+		{client.AnalogMappingQuery, "AnalogMappingQuery"},
+	} {
+		if err := f.board.WriteSysex([]byte{query.command}); err != nil {
+			return err
+		}
+		if !awaitEvent(events, f.board.Event(query.event), f.timeouts.Handshake) {
+			return ErrCapabilityTimeout
+		}
+	}
+	return nil
+}
+
+// awaitEvent waits for the event name on events and reports whether it was
+// received within timeout. A zero timeout waits forever.
+func awaitEvent(events chan *gobot.Event, name string, timeout time.Duration) bool {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	for {
+		select {
+		case evt := <-events:
+			if evt.Name == name {
+				return true
+			}
+		case <-expired:
+			return false
+		}
+	}
+}
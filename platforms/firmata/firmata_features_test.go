@@ -0,0 +1,60 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorSupportsFeature(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[3].SupportedModes = []int{client.Input, client.Output, client.Pwm}
+	a.board.Pins()[18].SupportedModes = []int{client.Analog, client.I2c}
+
+	for feature, expected := range map[string]bool{
+		"pwm": true, "i2c": true, "analog": true,
+		"servo": false, "tone": false, "unknown": false,
+	} {
+		gobottest.Assert(t, a.SupportsFeature(feature), expected)
+	}
+	gobottest.Assert(t, a.requireFeature("i2c"), nil)
+	gobottest.Assert(t, a.requireFeature("tone"), ErrNotSupported)
+	gobottest.Assert(t, a.requireFeature("unknown"), ErrNotSupported)
+}
+
+func TestAdaptorFeatureMethods(t *testing.T) {
+	a := initTestAdaptor()
+	// without a capability response nothing is refused
+	gobottest.Assert(t, a.ServoWrite("5", 90), nil)
+
+	a = initTestAdaptor()
+	a.board.Pins()[3].SupportedModes = []int{client.Input, client.Output, client.Pwm}
+	gobottest.Assert(t, a.I2cStart(0x10), ErrNotSupported)
+	gobottest.Assert(t, a.ServoWrite("3", 90), ErrNotSupported)
+	gobottest.Assert(t, a.PwmWrite("3", 10), nil)
+}
+
+func TestAdaptorRefreshCapabilities(t *testing.T) {
+	a := initTestAdaptor()
+	board := a.board.(*mockFirmataBoard)
+	before := len(*board.sysex)
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		board.Publish("CapabilityQuery", nil)
+		board.Publish("AnalogMappingQuery", nil)
+	}()
+
+	gobottest.Assert(t, a.RefreshCapabilities(), nil)
+	gobottest.Assert(t, (*board.sysex)[before:], [][]byte{
+		{client.CapabilityQuery},
+		{client.AnalogMappingQuery},
+	})
+
+	a.timeouts.Handshake = 10 * time.Millisecond
+	gobottest.Assert(t, a.RefreshCapabilities(), ErrCapabilityTimeout)
+
+	board.disconnected = true
+	gobottest.Assert(t, a.RefreshCapabilities(), ErrNotConnected)
+}
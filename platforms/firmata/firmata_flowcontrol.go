@@ -0,0 +1,40 @@
+package firmata
+
+// flowController is implemented by connections which can switch RTS/CTS
+// hardware flow control.
+type flowController interface {
+	SetFlowControl(rtscts bool) error
+}
+
+// WithFlowControl enables RTS/CTS hardware flow control on the connection,
+// which keeps some USB-serial bridges from dropping bytes at high baud rates.
+// It applies to connections which implement
+//
+//	SetFlowControl(rtscts bool) error
+//
+// whether opened by the Adaptor or passed to NewAdaptor. The serial ports the
+// Adaptor opens itself cannot switch flow control, so for them, as for any
+// other connection which does not support it, the option is ignored with a
+// debug log.
+func WithFlowControl(enabled bool) Option {
+	return func(f *Adaptor) {
+		f.flowControl = enabled
+	}
+}
+
+// setupFlowControl applies WithFlowControl to the connection.
+func (f *Adaptor) setupFlowControl() error {
+	if !f.flowControl {
+		return nil
+	}
+	conn := f.conn
+	if counting, ok := conn.(*countingConn); ok {
+		conn = counting.ReadWriteCloser
+	}
+	c, ok := conn.(flowController)
+	if !ok {
+		f.logger.Debugf("firmata: %v does not support flow control, ignoring it", f.Label())
+		return nil
+	}
+	return c.SetFlowControl(true)
+}
@@ -0,0 +1,49 @@
+package firmata
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+type flowControlConn struct {
+	readWriteCloser
+	enabled *bool
+	err     error
+}
+
+func (c flowControlConn) SetFlowControl(rtscts bool) error {
+	*c.enabled = rtscts
+	return c.err
+}
+
+func TestAdaptorFlowControl(t *testing.T) {
+	enabled := false
+	a := NewAdaptor(flowControlConn{enabled: &enabled}, WithFlowControl(true))
+	a.board = newMockFirmataBoard()
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, enabled, true)
+
+	// reconnecting applies it to the same connection again
+	enabled = false
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, enabled, true)
+
+	a = NewAdaptor(flowControlConn{enabled: &enabled, err: errors.New("no rts")}, WithFlowControl(true))
+	a.board = newMockFirmataBoard()
+	gobottest.Assert(t, a.Connect(), errors.New("no rts"))
+}
+
+func TestAdaptorFlowControlUnsupported(t *testing.T) {
+	l := &testLogger{}
+	a := NewAdaptor("/dev/null", WithFlowControl(true), WithLogger(l))
+	a.board = newMockFirmataBoard()
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		return &readWriteCloser{}, nil
+	}
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, l.messages[0], "debug: firmata: /dev/null does not support flow control, ignoring it")
+}
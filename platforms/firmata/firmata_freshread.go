@@ -0,0 +1,84 @@
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// DefaultFreshReadTimeout is the default time DigitalReadFresh waits for the
+// board to send the current value of the pin.
+const DefaultFreshReadTimeout = 100 * time.Millisecond
+
+// Errors
+var (
+	ErrReadTimeout = errors.New("timed out waiting for the board to report the pin")
+)
+
+// WithFreshReadTimeout sets how long DigitalReadFresh waits for the board to
+// send the current value of the pin before returning ErrReadTimeout.
+func WithFreshReadTimeout(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.FreshRead = d
+	}
+}
+
+// DigitalReadFresh is like DigitalRead, but returns a value sampled by the
+// board after the call, rather than the last one reported. It costs a round
+// trip to the board, so it suits readings which must be current more than
+// frequent polling. The pin state query cannot be used for this, as it
+// reports the value written to a pin rather than the one read from it, so
+// instead digital reporting for the port of the pin is enabled again, which
+// makes the firmware send the current values of the port at once. Returns
+// ErrReadTimeout if they do not arrive within the time set with
+// WithFreshReadTimeout. Only a report received after the request counts, as
+// far as the board records when the reports of a pin arrive, which
+// client.Client does. Pins set up with SetDebounce are read without
+// debouncing.
+func (f *Adaptor) DigitalReadFresh(pin string) (val int, err error) {
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return
+	}
+	if _, err = f.ensureMode(p, client.Input); err != nil {
+		return
+	}
+
+	events := f.board.Subscribe()
+	defer unsubscribe(f.board, events)
+	sent := time.Now()
+	release, err := f.refreshReport(digitalReport, p/8)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	name := f.boardEvent(fmt.Sprintf("DigitalRead%v", p))
+	expired := time.After(f.timeouts.FreshRead)
+	for {
+		select {
+		case evt := <-events:
+			value, ok := evt.Data.(int)
+			if evt.Name != name || !ok {
+				continue
+			}
+			// a report received before the request may still be on its way
+			// to the subscription, so only a pin updated since counts
+			if state, _ := f.pin(p); !state.Updated.IsZero() {
+				if state.Updated.Before(sent) {
+					continue
+				}
+				value = state.Value
+			}
+			if f.isInverted(p) {
+				value = int(invertLevel(byte(value)))
+			}
+			return value, nil
+		case <-expired:
+			return 0, ErrReadTimeout
+		}
+	}
+}
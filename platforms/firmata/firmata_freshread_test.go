@@ -0,0 +1,75 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorDigitalReadFresh(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	a.board.Pins()[10].Value = 0
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish("DigitalRead9", 1)
+		a.board.Publish("DigitalRead10", 1)
+	}()
+
+	val, err := a.DigitalReadFresh("10")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+	gobottest.Assert(t, *reports, [][3]int{
+		{int(client.ReportDigital), 1, 1},
+		{int(client.ReportDigital), 1, 0},
+	})
+
+	// reporting needed by a watch is left on
+	cancel, err := a.Watch("10", func(int) {})
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+	*reports = (*reports)[:0]
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish("DigitalRead10", 0)
+	}()
+	gobottest.Assert(t, a.SetInverted("10", true), nil)
+	val, err = a.DigitalReadFresh("10")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+	gobottest.Assert(t, *reports, [][3]int{{int(client.ReportDigital), 1, 1}})
+}
+
+func TestAdaptorDigitalReadFreshSkipsStaleReports(t *testing.T) {
+	a := initTestAdaptor()
+	board := a.board.(*mockFirmataBoard)
+	board.pins[10].Mode = client.Input
+	board.pins[10].Updated = time.Now().Add(-time.Second)
+	go func() {
+		// a report received before the call, published late
+		<-time.After(10 * time.Millisecond)
+		board.Publish("DigitalRead10", 0)
+		<-time.After(10 * time.Millisecond)
+		board.mutex.Lock()
+		board.pins[10].Value = 1
+		board.pins[10].Updated = time.Now()
+		board.mutex.Unlock()
+		board.Publish("DigitalRead10", 1)
+	}()
+
+	val, err := a.DigitalReadFresh("10")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+}
+
+func TestAdaptorDigitalReadFreshTimeout(t *testing.T) {
+	a := initTestAdaptor()
+	WithFreshReadTimeout(10 * time.Millisecond)(a)
+	_, err := a.DigitalReadFresh("10")
+	gobottest.Assert(t, err, ErrReadTimeout)
+
+	_, err = a.DigitalReadFresh("x")
+	gobottest.Refute(t, err, nil)
+}
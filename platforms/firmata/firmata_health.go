@@ -0,0 +1,158 @@
+package firmata
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health is the data published with the Adaptor's "Health" event.
+type Health struct {
+	// Connected reports whether the board is currently connected.
+	Connected bool
+	// RTT is the round trip time of the most recent protocol version query,
+	// or zero if no reply has been received yet.
+	RTT time.Duration
+	// BytesRead is the number of bytes read from the connection.
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to the connection.
+	BytesWritten uint64
+}
+
+// WithHealthInterval makes the Adaptor publish a "Health" event every d while
+// it is connected. A zero interval, the default, disables the event.
+func WithHealthInterval(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.health.interval = d
+	}
+}
+
+type healthMonitor struct {
+	interval     time.Duration
+	bytesRead    uint64
+	bytesWritten uint64
+	rtt          int64
+	sent         int64
+	done         chan struct{}
+	mutex        sync.Mutex
+}
+
+func newHealthMonitor() *healthMonitor {
+	return &healthMonitor{}
+}
+
+// wrap returns conn wrapped so that bytes passing through it are counted.
+func (h *healthMonitor) wrap(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	if c, ok := conn.(*countingConn); ok {
+		return c
+	}
+	return &countingConn{ReadWriteCloser: conn, health: h}
+}
+
+// errNoDeadline is returned by countingConn.SetReadDeadline when the wrapped
+// connection has no read deadline.
+var errNoDeadline = errors.New("connection does not support read deadlines")
+
+// errNoFlush is returned by countingConn.Flush when the wrapped connection
+// cannot be flushed.
+var errNoFlush = errors.New("connection does not support flushing")
+
+type countingConn struct {
+	io.ReadWriteCloser
+	health *healthMonitor
+}
+
+func (c *countingConn) Read(p []byte) (n int, err error) {
+	n, err = c.ReadWriteCloser.Read(p)
+	atomic.AddUint64(&c.health.bytesRead, uint64(n))
+	return
+}
+
+// SetReadDeadline sets the read deadline of the wrapped connection, so that
+// the Client can still use one through the wrapper.
+func (c *countingConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface {
+		SetReadDeadline(time.Time) error
+	}); ok {
+		return d.SetReadDeadline(t)
+	}
+	return errNoDeadline
+}
+
+// Flush discards the pending input of the wrapped connection, such as a
+// serial port, so that the Client can still flush it through the wrapper.
+func (c *countingConn) Flush() error {
+	if f, ok := c.ReadWriteCloser.(interface {
+		Flush() error
+	}); ok {
+		return f.Flush()
+	}
+	return errNoFlush
+}
+
+func (c *countingConn) Write(p []byte) (n int, err error) {
+	n, err = c.ReadWriteCloser.Write(p)
+	atomic.AddUint64(&c.health.bytesWritten, uint64(n))
+	return
+}
+
+// measureRTT handles the protocol version replies of the board, measuring the
+// round trip time of the queries sent by the health ticker.
+func (h *healthMonitor) measureRTT(interface{}) {
+	if sent := atomic.SwapInt64(&h.sent, 0); sent != 0 {
+		atomic.StoreInt64(&h.rtt, time.Now().UnixNano()-sent)
+	}
+}
+
+func (f *Adaptor) startHealth() {
+	h := f.health
+	if h.interval <= 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.done != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	h.done = done
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				f.Publish(f.Event("Health"), f.currentHealth())
+				if f.board.Connected() {
+					atomic.StoreInt64(&h.sent, time.Now().UnixNano())
+					f.board.ProtocolVersionQuery()
+				}
+			}
+		}
+	}()
+}
+
+func (f *Adaptor) stopHealth() {
+	h := f.health
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.done != nil {
+		close(h.done)
+		h.done = nil
+	}
+}
+
+func (f *Adaptor) currentHealth() Health {
+	return Health{
+		Connected:    f.board.Connected(),
+		RTT:          time.Duration(atomic.LoadInt64(&f.health.rtt)),
+		BytesRead:    atomic.LoadUint64(&f.health.bytesRead),
+		BytesWritten: atomic.LoadUint64(&f.health.bytesWritten),
+	}
+}
@@ -0,0 +1,58 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorHealth(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithHealthInterval(5*time.Millisecond))
+	a.board = newMockFirmataBoard()
+	sem := make(chan Health, 1)
+	a.Once(a.Event("Health"), func(data interface{}) {
+		sem <- data.(Health)
+	})
+	gobottest.Assert(t, a.Connect(), nil)
+
+	select {
+	case h := <-sem:
+		gobottest.Assert(t, h.Connected, true)
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Health was not published")
+	}
+	gobottest.Assert(t, a.Disconnect(), nil)
+}
+
+func TestAdaptorHealthBytes(t *testing.T) {
+	a := initTestAdaptor()
+	a.conn.Write([]byte{0x01, 0x02})
+	gobottest.Assert(t, a.currentHealth().BytesWritten, uint64(2))
+}
+
+func TestAdaptorHealthDisabled(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.health.done == nil, true)
+}
+
+type flushConn struct {
+	readWriteCloser
+	flushes *int
+}
+
+func (c flushConn) Flush() error {
+	*c.flushes++
+	return nil
+}
+
+func TestCountingConnFlush(t *testing.T) {
+	flushes := 0
+	h := newHealthMonitor()
+	conn := h.wrap(flushConn{flushes: &flushes}).(*countingConn)
+	gobottest.Assert(t, conn.Flush(), nil)
+	gobottest.Assert(t, flushes, 1)
+
+	conn = h.wrap(&readWriteCloser{}).(*countingConn)
+	gobottest.Assert(t, conn.Flush(), errNoFlush)
+}
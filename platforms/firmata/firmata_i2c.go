@@ -0,0 +1,397 @@
+package firmata
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// I2cShutdownTimeout is how long Finalize waits for outstanding I2C
+// transactions to complete before cancelling them.
+const I2cShutdownTimeout = 100 * time.Millisecond
+
+// DefaultI2cTimeout is the default time I2cPing and I2cCommandRead wait for a
+// device to answer.
+const DefaultI2cTimeout = time.Second
+
+// i2cNackMessage is the string StandardFirmata reports when a device does not
+// answer a read request. It carries no address, but the firmware follows it
+// with the short reply to the same request, which identifies the device.
+const i2cNackMessage = "I2C: Too few bytes received"
+
+// i2cBlockMax is the largest block an SMBus block read can return.
+const i2cBlockMax = 32
+
+// Errors
+var (
+	ErrI2cClosed      = errors.New("i2c transaction cancelled by shutdown")
+	ErrI2cBlockLength = errors.New("i2c block length is invalid")
+	ErrI2cNack        = errors.New("i2c device did not acknowledge")
+	ErrI2cShortReply  = errors.New("i2c reply is shorter than requested")
+)
+
+// i2cTransactions tracks the I2C transactions in flight so that Finalize can
+// wait for them.
+type i2cTransactions struct {
+	pending sync.WaitGroup
+	done    chan struct{}
+	closing bool
+	started bool
+	mutex   sync.Mutex
+}
+
+// WithI2cBlockSize sets the largest block I2cBlockRead reads, from 1 to the
+// 32 bytes SMBus allows, which is the default. The Wire buffer of AVR boards
+// holds 32 bytes including the length byte, so their blocks are limited to 31.
+func WithI2cBlockSize(size int) Option {
+	return func(f *Adaptor) {
+		if size < 1 {
+			size = 1
+		}
+		if size > i2cBlockMax {
+			size = i2cBlockMax
+		}
+		f.i2cBlockSize = size
+	}
+}
+
+// WithI2cTimeout sets how long I2cPing and I2cCommandRead wait for a device
+// to answer.
+func WithI2cTimeout(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.I2c = d
+	}
+}
+
+// TenBitAddress marks address as a 10-bit I2C address, for use with I2cRead,
+// I2cWrite and the other I2C methods.
+func TenBitAddress(address int) int {
+	return address | client.I2cTenBit
+}
+
+// I2cStart starts an i2c device at specified address. The bus is configured
+// by the first call only, so that drivers sharing it do not disturb each
+// other's transactions, and is configured again on every connect since the
+// board forgets it. Returns ErrNotSupported if the firmware has no I2C
+// support.
+func (f *Adaptor) I2cStart(address int) (err error) {
+	if err = f.requireFeature("i2c"); err != nil {
+		return
+	}
+	f.i2c.mutex.Lock()
+	defer f.i2c.mutex.Unlock()
+	if f.i2c.started {
+		return nil
+	}
+	if err = f.board.I2cConfig(0); err != nil {
+		return
+	}
+	f.i2c.started = true
+	return
+}
+
+// restoreI2c configures the bus again after a connect if it was started, and
+// starts the continuous reads again.
+func (f *Adaptor) restoreI2c() error {
+	if f.i2cStarted() {
+		if err := f.board.I2cConfig(0); err != nil {
+			return err
+		}
+	}
+	return f.restoreI2cStreams()
+}
+
+// i2cStarted reports whether the I2C bus has been configured, which claims
+// the pins supporting I2C mode.
+func (f *Adaptor) i2cStarted() bool {
+	f.i2c.mutex.Lock()
+	defer f.i2c.mutex.Unlock()
+	return f.i2c.started
+}
+
+// I2cRead returns size bytes from the i2c device. If the Adaptor is finalized
+// while the read is waiting for a reply, it returns ErrI2cClosed.
+func (f *Adaptor) I2cRead(address int, size int) (data []byte, err error) {
+	return f.I2cReadContext(context.Background(), address, size)
+}
+
+// I2cReadContext is like I2cRead but stops waiting for the reply when ctx is
+// done, returning ctx.Err().
+func (f *Adaptor) I2cReadContext(ctx context.Context, address int, size int) (data []byte, err error) {
+	return f.i2cReply(ctx, address, func() error {
+		return f.board.I2cRead(address, size)
+	})
+}
+
+// I2cReadRaw is like I2cRead but controls how the read ends. With sendStop
+// the master releases the bus with a stop condition, as I2cRead does. Without
+// it the read ends with a repeated start and the master keeps the bus, for
+// devices which expect the next request to follow without a stop in between.
+// Firmware which does not support the auto restart bit always sends a stop.
+func (f *Adaptor) I2cReadRaw(address int, size int, sendStop bool) (data []byte, err error) {
+	return f.I2cReadContext(context.Background(), i2cStopAddress(address, sendStop), size)
+}
+
+// I2cPing reports whether a device answers at address. Firmata does not report
+// whether a write was acknowledged, so the zero-length write alone cannot tell;
+// a single byte is read as well and the device is present if the read is
+// answered in full. The read is the least that proves a device is there, but
+// on devices where reading has side effects, such as advancing a FIFO, it
+// consumes a byte. Returns false with no error when the firmware reports the
+// device did not answer, and an error if nothing is heard within the I2C
+// timeout.
+func (f *Adaptor) I2cPing(address int) (bool, error) {
+	if err := f.board.I2cWrite(address, []byte{}); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeouts.I2c)
+	defer cancel()
+	_, err := f.I2cReadContext(ctx, address, 1)
+	switch err {
+	case nil:
+		return true, nil
+	case ErrI2cNack:
+		return false, nil
+	}
+	return false, err
+}
+
+// I2cBlockRead performs an SMBus block read of register on the i2c device. A
+// single read of register returns the length byte followed by the block, of
+// which the data is returned. The read asks for as many bytes as the largest
+// block set with WithI2cBlockSize, and returns ErrI2cBlockLength if the device
+// reports a longer block or returns fewer bytes than it reports.
+func (f *Adaptor) I2cBlockRead(address int, register int) (data []byte, err error) {
+	reply, err := f.i2cReply(context.Background(), address, func() error {
+		return f.i2cReadRegister(address, register, f.i2cBlockSize+1)
+	})
+	if err != nil {
+		return
+	}
+	if len(reply) == 0 {
+		return nil, ErrI2cBlockLength
+	}
+
+	n := int(reply[0])
+	if n > f.i2cBlockSize || len(reply) < n+1 {
+		return nil, ErrI2cBlockLength
+	}
+	return reply[1 : n+1], nil
+}
+
+// I2cReadWord reads the 16-bit value of register on the i2c device, taking the
+// first byte as the most significant, as ADCs and IMUs commonly send it. The
+// raw bytes can still be read with I2cRead.
+func (f *Adaptor) I2cReadWord(address int, register int) (uint16, error) {
+	return f.i2cReadWord(address, register, binary.BigEndian)
+}
+
+// I2cReadWordLE is like I2cReadWord for devices which send the least
+// significant byte first, as an SMBus word read does.
+func (f *Adaptor) I2cReadWordLE(address int, register int) (uint16, error) {
+	return f.i2cReadWord(address, register, binary.LittleEndian)
+}
+
+// i2cReadWord reads two bytes from register of the i2c device and assembles
+// them in the given byte order. Returns ErrI2cShortReply if fewer arrive.
+func (f *Adaptor) i2cReadWord(address int, register int, order binary.ByteOrder) (uint16, error) {
+	reply, err := f.i2cReply(context.Background(), address, func() error {
+		return f.i2cReadRegister(address, register, 2)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(reply) < 2 {
+		return 0, ErrI2cShortReply
+	}
+	return order.Uint16(reply), nil
+}
+
+// I2cCommandRead writes cmd to the i2c device, waits delay for the device to
+// act on it, such as completing a measurement, and then reads size bytes. The
+// read waits for the reply up to the I2C timeout set with WithI2cTimeout.
+func (f *Adaptor) I2cCommandRead(address int, cmd []byte, delay time.Duration, size int) (data []byte, err error) {
+	if err = f.board.I2cWrite(address, cmd); err != nil {
+		return
+	}
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeouts.I2c)
+	defer cancel()
+	return f.I2cReadContext(ctx, address, size)
+}
+
+// i2cReply sends a read request using request and waits for the reply from
+// address, until ctx is done. Returns ErrI2cNack if the firmware reports the
+// device did not answer.
+func (f *Adaptor) i2cReply(ctx context.Context, address int, request func() error) (data []byte, err error) {
+	done, err := f.beginI2c()
+	if err != nil {
+		return
+	}
+	defer f.i2c.pending.Done()
+
+	events := f.board.Subscribe()
+	defer unsubscribe(f.board, events)
+
+	if err = request(); err != nil {
+		return
+	}
+
+	name := f.board.Event("I2cReply")
+	stringData := f.board.Event("StringData")
+	nack := false
+	for {
+		select {
+		case evt := <-events:
+			switch evt.Name {
+			case name:
+				reply, ok := evt.Data.(client.I2cReply)
+				if !ok {
+					continue
+				}
+				if reply.Address == address&^(client.I2cTenBit|client.I2cRestart) {
+					if nack {
+						return nil, ErrI2cNack
+					}
+					return reply.Data, nil
+				}
+				// the nack, if any, was for this other device
+				nack = false
+			case stringData:
+				if evt.Data == i2cNackMessage {
+					nack = true
+				}
+			}
+		case <-done:
+			return nil, ErrI2cClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// I2cWrite writes data to i2c device
+func (f *Adaptor) I2cWrite(address int, data []byte) (err error) {
+	return f.board.I2cWrite(address, data)
+}
+
+// I2cWriteContext is like I2cWrite but stops waiting for the write to reach
+// the connection when ctx is done, returning ctx.Err(). A write which has
+// already started cannot be taken back, so it may still reach the device
+// after I2cWriteContext has returned.
+func (f *Adaptor) I2cWriteContext(ctx context.Context, address int, data []byte) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f.board.I2cWrite(address, data)
+	}()
+	select {
+	case err = <-done:
+		return
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// I2cWriteRaw is like I2cWrite but controls how the write ends, as
+// I2cReadRaw does for reads. Writing without sendStop and reading next is how
+// a register is selected on devices which lose it at a stop condition.
+func (f *Adaptor) I2cWriteRaw(address int, data []byte, sendStop bool) (err error) {
+	return f.board.I2cWrite(i2cStopAddress(address, sendStop), data)
+}
+
+// i2cStopAddress marks address to end its request with a repeated start
+// unless sendStop is set.
+func i2cStopAddress(address int, sendStop bool) int {
+	if sendStop {
+		return address
+	}
+	return address | client.I2cRestart
+}
+
+// I2cWriteRegister writes data to register of the i2c device, by sending the
+// register byte followed by data in a single write.
+func (f *Adaptor) I2cWriteRegister(address int, register int, data []byte) (err error) {
+	return f.board.I2cWrite(address, append([]byte{byte(register)}, data...))
+}
+
+// beginI2c registers a new transaction and returns the channel which is
+// closed when it must be abandoned.
+func (f *Adaptor) beginI2c() (<-chan struct{}, error) {
+	t := &f.i2c
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closing {
+		return nil, ErrI2cClosed
+	}
+	if t.done == nil {
+		t.done = make(chan struct{})
+	}
+	t.pending.Add(1)
+	return t.done, nil
+}
+
+// resetI2c allows new transactions after a Finalize.
+func (f *Adaptor) resetI2c() {
+	t := &f.i2c
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.closing = false
+}
+
+// finishI2c stops new transactions from starting and waits up to timeout for
+// those in flight to complete, cancelling any which are still waiting.
+func (f *Adaptor) finishI2c(timeout time.Duration) {
+	t := &f.i2c
+	t.mutex.Lock()
+	t.closing = true
+	done := t.done
+	t.done = nil
+	t.mutex.Unlock()
+
+	if done == nil {
+		return
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		t.pending.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		close(done)
+		<-finished
+	}
+}
+
+// registerReader is implemented by boards which can read a register of an
+// i2c device with one request, such as client.Client.
+type registerReader interface {
+	I2cReadRegister(int, int, int) error
+}
+
+// i2cReadRegister requests size bytes from register of the i2c device. Boards
+// which cannot read a register directly write the register first and then
+// read from the device.
+func (f *Adaptor) i2cReadRegister(address int, register int, size int) error {
+	if b, ok := f.board.(registerReader); ok {
+		return b.I2cReadRegister(address, register, size)
+	}
+	if err := f.board.I2cWrite(address, []byte{byte(register)}); err != nil {
+		return err
+	}
+	return f.board.I2cRead(address, size)
+}
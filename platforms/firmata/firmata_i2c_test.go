@@ -0,0 +1,299 @@
+package firmata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorI2cStart(t *testing.T) {
+	a := initTestAdaptor()
+	configs := a.board.(*mockFirmataBoard).configs
+	gobottest.Assert(t, a.I2cStart(0x00), nil)
+	gobottest.Assert(t, *configs, 1)
+
+	// drivers sharing the bus do not configure it again
+	gobottest.Assert(t, a.I2cStart(0x1E), nil)
+	gobottest.Assert(t, *configs, 1)
+
+	// the board forgets the configuration when the connection is lost
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, *configs, 2)
+	gobottest.Assert(t, a.I2cStart(0x1E), nil)
+	gobottest.Assert(t, *configs, 2)
+}
+
+func TestAdaptorI2cRead(t *testing.T) {
+	a := initTestAdaptor()
+	i := []byte{100}
+	i2cReply := client.I2cReply{Data: i}
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), i2cReply)
+	}()
+	data, err := a.I2cRead(0x00, 1)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, i)
+}
+
+func TestAdaptorI2cReadTenBit(t *testing.T) {
+	a := initTestAdaptor()
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x2A5, Data: []byte{7}})
+	}()
+	data, err := a.I2cRead(TenBitAddress(0x2A5), 1)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{7})
+}
+
+func TestAdaptorI2cReadRaw(t *testing.T) {
+	a := initTestAdaptor()
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x2A, Data: []byte{7}})
+	}()
+	data, err := a.I2cReadRaw(0x2A, 1, false)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{7})
+}
+
+func TestAdaptorI2cWriteRaw(t *testing.T) {
+	a := initTestAdaptor()
+	var addresses []int
+	a.board.(*mockFirmataBoard).i2cWrite = func(address int, data []byte) {
+		addresses = append(addresses, address)
+	}
+	gobottest.Assert(t, a.I2cWriteRaw(0x2A, []byte{0x01}, false), nil)
+	gobottest.Assert(t, a.I2cWriteRaw(0x2A, []byte{0x01}, true), nil)
+	gobottest.Assert(t, addresses, []int{0x2A | client.I2cRestart, 0x2A})
+}
+
+func TestAdaptorI2cCommandRead(t *testing.T) {
+	a := initTestAdaptor()
+	written := make(chan time.Time, 1)
+	a.board.(*mockFirmataBoard).i2cWrite = func(address int, data []byte) {
+		gobottest.Assert(t, data, []byte{0x33})
+		written <- time.Now()
+	}
+	go func() {
+		<-time.After(30 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x2A, Data: []byte{1, 2}})
+	}()
+
+	data, err := a.I2cCommandRead(0x2A, []byte{0x33}, 20*time.Millisecond, 2)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{1, 2})
+	gobottest.Assert(t, time.Since(<-written) >= 20*time.Millisecond, true)
+
+	WithI2cTimeout(10 * time.Millisecond)(a)
+	_, err = a.I2cCommandRead(0x2A, []byte{0x33}, 0, 2)
+	gobottest.Assert(t, err, context.DeadlineExceeded)
+}
+
+func TestAdaptorI2cWrite(t *testing.T) {
+	a := initTestAdaptor()
+	a.I2cWrite(0x00, []byte{0x00, 0x01})
+}
+
+func TestAdaptorI2cWriteContext(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.I2cWriteContext(context.Background(), 0x2A, []byte{0x01}), nil)
+
+	block := make(chan struct{})
+	defer close(block)
+	a.board.(*mockFirmataBoard).i2cWrite = func(int, []byte) {
+		<-block
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	gobottest.Assert(t, a.I2cWriteContext(ctx, 0x2A, []byte{0x01}), context.DeadlineExceeded)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	gobottest.Assert(t, a.I2cWriteContext(ctx, 0x2A, []byte{0x01}), context.Canceled)
+}
+
+func TestAdaptorFinalizeCancelsI2cRead(t *testing.T) {
+	a := initTestAdaptor()
+	result := make(chan error)
+	go func() {
+		_, err := a.I2cRead(0x00, 1)
+		result <- err
+	}()
+	<-time.After(10 * time.Millisecond)
+
+	gobottest.Assert(t, a.Finalize(), nil)
+	select {
+	case err := <-result:
+		gobottest.Assert(t, err, ErrI2cClosed)
+	case <-time.After(time.Second):
+		t.Errorf("I2cRead did not return after Finalize")
+	}
+
+	_, err := a.I2cRead(0x00, 1)
+	gobottest.Assert(t, err, ErrI2cClosed)
+}
+
+func TestAdaptorFinalizeWaitsForI2cRead(t *testing.T) {
+	a := initTestAdaptor()
+	result := make(chan error)
+	go func() {
+		_, err := a.I2cRead(0x00, 1)
+		result <- err
+	}()
+	go func() {
+		<-time.After(20 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Data: []byte{1}})
+	}()
+	<-time.After(10 * time.Millisecond)
+
+	gobottest.Assert(t, a.Finalize(), nil)
+	gobottest.Assert(t, <-result, nil)
+}
+
+func TestAdaptorI2cBlockRead(t *testing.T) {
+	a := initTestAdaptor()
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x0C, Data: []byte{9}})
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x0B, Data: []byte{2, 0x10, 0x20, 0xFF}})
+	}()
+	data, err := a.I2cBlockRead(0x0B, 0x30)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{0x10, 0x20})
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).i2cReads, [][3]int{{0x0B, 0x30, 33}})
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x0B, Data: []byte{33}})
+	}()
+	_, err = a.I2cBlockRead(0x0B, 0x30)
+	gobottest.Assert(t, err, ErrI2cBlockLength)
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x0B, Data: []byte{3, 0x10}})
+	}()
+	_, err = a.I2cBlockRead(0x0B, 0x30)
+	gobottest.Assert(t, err, ErrI2cBlockLength)
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x0B, Data: []byte{0}})
+	}()
+	data, err = a.I2cBlockRead(0x0B, 0x30)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{})
+}
+
+func TestAdaptorI2cBlockReadSize(t *testing.T) {
+	a := initTestAdaptor()
+	WithI2cBlockSize(31)(a)
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x0B, Data: []byte{32}})
+	}()
+	_, err := a.I2cBlockRead(0x0B, 0x30)
+	gobottest.Assert(t, err, ErrI2cBlockLength)
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).i2cReads, [][3]int{{0x0B, 0x30, 32}})
+
+	WithI2cBlockSize(0)(a)
+	gobottest.Assert(t, a.i2cBlockSize, 1)
+	WithI2cBlockSize(100)(a)
+	gobottest.Assert(t, a.i2cBlockSize, 32)
+}
+
+func TestAdaptorI2cReadWord(t *testing.T) {
+	a := initTestAdaptor()
+	reply := func(data ...byte) {
+		go func() {
+			<-time.After(10 * time.Millisecond)
+			a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x48, Data: data})
+		}()
+	}
+
+	reply(0x12, 0x34)
+	word, err := a.I2cReadWord(0x48, 0x01)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, word, uint16(0x1234))
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).i2cReads, [][3]int{{0x48, 0x01, 2}})
+
+	reply(0x12, 0x34)
+	word, err = a.I2cReadWordLE(0x48, 0x01)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, word, uint16(0x3412))
+
+	reply(0x12)
+	_, err = a.I2cReadWord(0x48, 0x01)
+	gobottest.Assert(t, err, ErrI2cShortReply)
+}
+
+func TestAdaptorI2cWriteRegister(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.I2cWriteRegister(0x1E, 0x02, []byte{0x00, 0x01}), nil)
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).i2c, [][]byte{{0x02, 0x00, 0x01}})
+}
+
+func TestAdaptorI2cReadContext(t *testing.T) {
+	a := initTestAdaptor()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := a.I2cReadContext(ctx, 0x00, 1)
+	gobottest.Assert(t, err, context.DeadlineExceeded)
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Data: []byte{7}})
+	}()
+	data, err := a.I2cReadContext(context.Background(), 0x00, 1)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{7})
+}
+
+func TestAdaptorI2cPing(t *testing.T) {
+	a := initTestAdaptor()
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x40, Data: []byte{0}})
+	}()
+	ok, err := a.I2cPing(0x40)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, ok, true)
+	gobottest.Assert(t, (*a.board.(*mockFirmataBoard).i2c)[0], []byte{})
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("StringData"), "I2C: Too few bytes received")
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x41, Data: []byte{}})
+	}()
+	ok, err = a.I2cPing(0x41)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, ok, false)
+}
+
+func TestAdaptorI2cNackOtherDevice(t *testing.T) {
+	a := initTestAdaptor()
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		a.board.Publish(a.board.Event("StringData"), "I2C: Too few bytes received")
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x41, Data: []byte{}})
+		a.board.Publish(a.board.Event("I2cReply"), client.I2cReply{Address: 0x40, Data: []byte{5}})
+	}()
+	data, err := a.I2cRead(0x40, 1)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{5})
+}
+
+func TestAdaptorI2cPingTimeout(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithI2cTimeout(10*time.Millisecond))
+	a.board = newMockFirmataBoard()
+	a.Connect()
+	ok, err := a.I2cPing(0x40)
+	gobottest.Assert(t, err, context.DeadlineExceeded)
+	gobottest.Assert(t, ok, false)
+}
@@ -0,0 +1,158 @@
+package firmata
+
+import (
+	"sort"
+	"sync"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// i2cStreamBuffer is the number of replies buffered for each continuous read.
+// Replies arriving while the buffer is full are dropped.
+const i2cStreamBuffer = 16
+
+// I2cReadToken identifies a continuous read started with I2cReadContinuous.
+type I2cReadToken int
+
+// continuousReader is implemented by boards which can read an i2c device
+// continuously, such as client.Client.
+type continuousReader interface {
+	I2cReadContinuous(int, int, int) error
+	I2cStopReading(int) error
+}
+
+// i2cStream is a continuous read in progress.
+type i2cStream struct {
+	address  int
+	register int
+	size     int
+	data     chan []byte
+	cancel   func()
+}
+
+// i2cStreams holds the continuous reads in progress by token.
+type i2cStreams struct {
+	streams map[I2cReadToken]*i2cStream
+	next    I2cReadToken
+	mutex   sync.Mutex
+}
+
+// I2cReadContinuous makes the board read size bytes from register of the i2c
+// device at address repeatedly, at the sampling interval, and returns a
+// channel receiving each reading, with a token which stops the read in
+// StopI2cRead. Readings arriving while the channel is full are dropped. The
+// read is started again after a reconnect. Continuous reads of the same
+// register of a device cannot be told apart, so each receives the readings
+// of all of them. Returns ErrNotSupported for boards which cannot read
+// continuously.
+func (f *Adaptor) I2cReadContinuous(address int, register int, size int) (<-chan []byte, I2cReadToken, error) {
+	reader, ok := f.board.(continuousReader)
+	if !ok {
+		return nil, 0, ErrNotSupported
+	}
+
+	t := &f.i2cStreams
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s := &i2cStream{
+		address:  address,
+		register: register,
+		size:     size,
+		data:     make(chan []byte, i2cStreamBuffer),
+	}
+	device := address &^ (client.I2cTenBit | client.I2cRestart)
+	s.cancel = f.OnI2cReply(func(reply client.I2cReply) {
+		if reply.Address != device || reply.Register != register {
+			return
+		}
+		select {
+		case s.data <- reply.Data:
+		default:
+		}
+	})
+	if err := reader.I2cReadContinuous(address, register, size); err != nil {
+		s.cancel()
+		return nil, 0, err
+	}
+
+	if t.streams == nil {
+		t.streams = make(map[I2cReadToken]*i2cStream)
+	}
+	t.next++
+	t.streams[t.next] = s
+	return s.data, t.next, nil
+}
+
+// StopI2cRead stops the continuous read of token and closes its channel, which
+// receives nothing more once StopI2cRead returns. Other continuous reads of
+// the same device keep going: Firmata stops reads by address alone, so they
+// are stopped along with it and started again. Stopping a read again does
+// nothing.
+func (f *Adaptor) StopI2cRead(token I2cReadToken) (err error) {
+	t := &f.i2cStreams
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.streams[token]
+	if !ok {
+		return nil
+	}
+	delete(t.streams, token)
+	s.cancel()
+	close(s.data)
+
+	reader := f.board.(continuousReader)
+	device := s.address &^ (client.I2cTenBit | client.I2cRestart)
+	others := []*i2cStream{}
+	for _, o := range t.sortedStreams() {
+		if o.address&^(client.I2cTenBit|client.I2cRestart) == device {
+			others = append(others, o)
+		}
+	}
+	for i := 0; i <= len(others); i++ {
+		if err = reader.I2cStopReading(s.address); err != nil {
+			return err
+		}
+	}
+	for _, o := range others {
+		if err = reader.I2cReadContinuous(o.address, o.register, o.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedStreams returns the continuous reads in the order they were started.
+// It must be called with the mutex held.
+func (t *i2cStreams) sortedStreams() []*i2cStream {
+	tokens := make([]int, 0, len(t.streams))
+	for token := range t.streams {
+		tokens = append(tokens, int(token))
+	}
+	sort.Ints(tokens)
+	streams := make([]*i2cStream, len(tokens))
+	for i, token := range tokens {
+		streams[i] = t.streams[I2cReadToken(token)]
+	}
+	return streams
+}
+
+// restoreI2cStreams starts the continuous reads again after a connect, since
+// the board forgets them.
+func (f *Adaptor) restoreI2cStreams() error {
+	t := &f.i2cStreams
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.streams) == 0 {
+		return nil
+	}
+	reader := f.board.(continuousReader)
+	for _, s := range t.sortedStreams() {
+		if err := reader.I2cReadContinuous(s.address, s.register, s.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorI2cReadContinuous(t *testing.T) {
+	a := initTestAdaptor()
+	board := a.board.(*mockFirmataBoard)
+	start := int(client.I2CModeContinuousRead)
+	stop := int(client.I2CModeStopReading)
+
+	first, firstToken, err := a.I2cReadContinuous(0x48, 0, 2)
+	gobottest.Assert(t, err, nil)
+	second, secondToken, err := a.I2cReadContinuous(0x48, 2, 2)
+	gobottest.Assert(t, err, nil)
+	_, _, err = a.I2cReadContinuous(0x50, 0, 1)
+	gobottest.Assert(t, err, nil)
+	gobottest.Refute(t, firstToken, secondToken)
+	gobottest.Assert(t, *board.streams, [][4]int{
+		{start, 0x48, 0, 2}, {start, 0x48, 2, 2}, {start, 0x50, 0, 1},
+	})
+
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x48, Register: 2, Data: []byte{3, 4}})
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x48, Register: 0, Data: []byte{1, 2}})
+	for _, c := range []struct {
+		stream <-chan []byte
+		data   []byte
+	}{{first, []byte{1, 2}}, {second, []byte{3, 4}}} {
+		select {
+		case data := <-c.stream:
+			gobottest.Assert(t, data, c.data)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("reading was not delivered")
+		}
+	}
+
+	// stopping the first read leaves the second one on the same device going
+	*board.streams = nil
+	gobottest.Assert(t, a.StopI2cRead(firstToken), nil)
+	gobottest.Assert(t, *board.streams, [][4]int{
+		{stop, 0x48, 0, 0}, {stop, 0x48, 0, 0}, {start, 0x48, 2, 2},
+	})
+	_, open := <-first
+	gobottest.Assert(t, open, false)
+	a.board.Publish("I2cReply", client.I2cReply{Address: 0x48, Register: 2, Data: []byte{5, 6}})
+	select {
+	case data := <-second:
+		gobottest.Assert(t, data, []byte{5, 6})
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("reading was not delivered")
+	}
+
+	*board.streams = nil
+	gobottest.Assert(t, a.StopI2cRead(firstToken), nil)
+	gobottest.Assert(t, len(*board.streams), 0)
+
+	// the reads still in progress are started again on a reconnect
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, *board.streams, [][4]int{
+		{start, 0x48, 2, 2}, {start, 0x50, 0, 1},
+	})
+}
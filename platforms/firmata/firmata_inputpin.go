@@ -0,0 +1,86 @@
+package firmata
+
+import (
+	"fmt"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// InputPin is a handle for reading a pin quickly and repeatedly. Reporting is
+// enabled for the pin once, when the handle is created with Adaptor.InputPin,
+// and kept until Close is called, so that Read only returns the latest value
+// the board reported.
+type InputPin struct {
+	adaptor *Adaptor
+	pin     int
+	kind    reportKind
+	index   int
+	name    string
+	invert  bool
+	release func() error
+}
+
+// InputPin returns a handle for reading the pin, named as in Pin. A pin in
+// analog mode is read through analog reports; any other pin is switched to
+// input mode, keeping its pull-up if enabled, and read through digital
+// reports. Reads through the handle skip the name lookup, the mode check and
+// the wait for a first report of DigitalRead and AnalogRead. Whether the pin
+// is inverted with SetInverted is taken when the handle is created. Debouncing
+// set with SetDebounce does not apply to the handle.
+func (f *Adaptor) InputPin(pin string) (*InputPin, error) {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(pin)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	i := &InputPin{adaptor: f, pin: p}
+	if state, _ := f.pin(p); state.Mode == client.Analog {
+		i.kind, i.index = analogReport, state.AnalogChannel
+		i.name = f.boardEvent(fmt.Sprintf("AnalogRead%v", state.AnalogChannel))
+	} else {
+		if _, err = f.ensureMode(p, client.Input); err != nil {
+			return nil, err
+		}
+		i.kind, i.index = digitalReport, p/8
+		i.name = f.boardEvent(fmt.Sprintf("DigitalRead%v", p))
+		i.invert = f.isInverted(p)
+	}
+
+	if i.release, err = f.acquireReport(i.kind, i.index); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Number returns the number of the pin on the board.
+func (i *InputPin) Number() int {
+	return i.pin
+}
+
+// Read returns the latest value reported for the pin.
+func (i *InputPin) Read() int {
+	state, _ := i.adaptor.pin(i.pin)
+	return i.value(state.Value)
+}
+
+// Subscribe returns a channel on which every value reported for the pin is
+// delivered, and a function which ends the subscription and closes the
+// channel. Values arriving while the channel is full are dropped.
+func (i *InputPin) Subscribe() (<-chan int, func()) {
+	return i.adaptor.subscribeSamples(i.name, i.value)
+}
+
+// Close releases the reporting held by the handle, disabling it once no other
+// reader needs it. Close may be called more than once.
+func (i *InputPin) Close() error {
+	return i.release()
+}
+
+func (i *InputPin) value(value int) int {
+	if i.invert {
+		return int(invertLevel(byte(value)))
+	}
+	return value
+}
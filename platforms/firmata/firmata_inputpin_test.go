@@ -0,0 +1,86 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorInputPinDigital(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	*reports = (*reports)[:0]
+
+	p, err := a.InputPin("10")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, p.Number(), 10)
+	gobottest.Assert(t, a.board.Pins()[10].Mode, client.Input)
+	gobottest.Assert(t, *reports, [][3]int{{int(client.ReportDigital), 1, 1}})
+
+	a.board.Pins()[10].Value = 1
+	gobottest.Assert(t, p.Read(), 1)
+
+	samples, cancel := p.Subscribe()
+	a.board.Publish("DigitalRead10", 0)
+	select {
+	case v := <-samples:
+		gobottest.Assert(t, v, 0)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("value was not delivered")
+	}
+	cancel()
+
+	gobottest.Assert(t, p.Close(), nil)
+	gobottest.Assert(t, p.Close(), nil)
+	gobottest.Assert(t, (*reports)[1], [3]int{int(client.ReportDigital), 1, 0})
+}
+
+func TestAdaptorInputPinAnalogInverted(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[16].Mode = client.Analog
+	a.board.Pins()[16].AnalogChannel = 2
+	a.board.Pins()[16].Value = 512
+	p, err := a.InputPin("16")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, p.Read(), 512)
+	gobottest.Assert(t, a.IsReporting("16"), true)
+	p.Close()
+
+	gobottest.Assert(t, a.SetInverted("11", true), nil)
+	p, err = a.InputPin("11")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, p.Read(), 1)
+
+	_, err = a.InputPin("x")
+	gobottest.Assert(t, err, ErrInvalidPin)
+}
+
+func BenchmarkAdaptorDigitalRead(b *testing.B) {
+	a := initTestAdaptor()
+	a.DigitalRead("10")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.DigitalRead("10")
+	}
+}
+
+func BenchmarkAdaptorAnalogRead(b *testing.B) {
+	a := initTestAdaptor()
+	a.AnalogRead("1")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.AnalogRead("1")
+	}
+}
+
+func BenchmarkAdaptorInputPinRead(b *testing.B) {
+	a := initTestAdaptor()
+	p, _ := a.InputPin("10")
+	defer p.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Read()
+	}
+}
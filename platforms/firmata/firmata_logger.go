@@ -0,0 +1,16 @@
+package firmata
+
+import "gobot.io/x/gobot/platforms/firmata/client"
+
+// Logger is the interface the Adaptor logs through. Debugf reports protocol
+// details such as discarded data, Infof connection changes and Errorf
+// failures. It matches client.Logger, so one implementation serves both.
+type Logger interface {
+	client.Logger
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
@@ -0,0 +1,71 @@
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Debugf(format string, v ...interface{}) {
+	l.messages = append(l.messages, "debug: "+fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Infof(format string, v ...interface{}) {
+	l.messages = append(l.messages, "info: "+fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Errorf(format string, v ...interface{}) {
+	l.messages = append(l.messages, "error: "+fmt.Sprintf(format, v...))
+}
+
+func TestAdaptorWithLogger(t *testing.T) {
+	l := &testLogger{}
+	a := initTestAdaptor()
+	WithLogger(l)(a)
+
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	a.board.(*mockFirmataBoard).disconnectError = errors.New("close error")
+	a.Disconnect()
+
+	gobottest.Assert(t, l.messages, []string{
+		"info: firmata: disconnected from /dev/null",
+		"info: firmata: connected to /dev/null",
+		"error: firmata: disconnecting from /dev/null: close error",
+	})
+}
+
+func TestAdaptorWithLabel(t *testing.T) {
+	l := &testLogger{}
+	a := NewAdaptor(&readWriteCloser{}, WithLogger(l), WithBoard(newMockFirmataBoard()))
+	gobottest.Assert(t, a.Port(), "")
+	gobottest.Assert(t, a.Label(), "Firmata")
+
+	a = NewAdaptor(&readWriteCloser{}, WithLabel("arm"), WithLogger(l), WithBoard(newMockFirmataBoard()))
+	gobottest.Assert(t, a.Port(), "arm")
+	gobottest.Assert(t, a.Label(), "arm")
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, l.messages, []string{"info: firmata: connected to arm"})
+
+	// the port is still opened by its name
+	opened := ""
+	a = NewAdaptor("/dev/ttyACM0", WithLabel("arm"), WithBoard(newMockFirmataBoard()))
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		opened = port
+		return &readWriteCloser{}, nil
+	}
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, opened, "/dev/ttyACM0")
+	gobottest.Assert(t, a.Port(), "/dev/ttyACM0")
+	gobottest.Assert(t, a.Label(), "arm")
+}
+
+func TestAdaptorWithNilLogger(t *testing.T) {
+	a := NewAdaptor("/dev/null", WithLogger(nil))
+	gobottest.Assert(t, a.logger, Logger(nopLogger{}))
+}
@@ -0,0 +1,98 @@
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+const (
+	// modeConfirmTimeout is how long a mode change waits for the pin state
+	// reply which confirms it.
+	modeConfirmTimeout = 100 * time.Millisecond
+	// modeRetryDelay is the pause before a mode change is sent again.
+	modeRetryDelay = 10 * time.Millisecond
+)
+
+// Errors
+var (
+	ErrModeNotSet = errors.New("board did not confirm the pin mode")
+)
+
+// WithModeRetries makes every pin mode change be confirmed with a pin state
+// query, and sent again up to n times if the board reports another mode or
+// does not answer. Once the retries are used up, the operation which changed
+// the mode returns ErrModeNotSet. This helps on marginal serial links where a
+// mode change is occasionally lost. The default of zero sends the change once
+// without confirming it, as do boards which cannot query the pin state.
+func WithModeRetries(n int) Option {
+	return func(f *Adaptor) {
+		f.modeRetries = n
+	}
+}
+
+// setPinMode sets the mode of pin, confirming and retrying the change as set
+// by WithModeRetries.
+func (f *Adaptor) setPinMode(pin int, mode int) error {
+	querier, ok := f.board.(pinStateQuerier)
+	if f.modeRetries <= 0 || !ok {
+		return f.board.SetPinMode(pin, mode)
+	}
+
+	name := f.boardEvent(fmt.Sprintf("PinState%v", pin))
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			<-time.After(modeRetryDelay)
+		}
+		if err := f.board.SetPinMode(pin, mode); err != nil {
+			return err
+		}
+
+		events := f.board.Subscribe()
+		err := querier.PinStateQuery(pin)
+		confirmed := err == nil && awaitMode(events, name, mode)
+		unsubscribe(f.board, events)
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			return nil
+		}
+		f.logger.Debugf("firmata: mode %v of pin %v not confirmed", mode, pin)
+		if attempt >= f.modeRetries {
+			return ErrModeNotSet
+		}
+	}
+}
+
+// pinStateQuerier is implemented by boards which can query the state of a
+// pin, such as client.Client.
+type pinStateQuerier interface {
+	PinStateQuery(int) error
+}
+
+// awaitMode waits for the pin state event name and reports whether it shows
+// the pin in mode.
+func awaitMode(events chan *gobot.Event, name string, mode int) bool {
+	state, ok := awaitPinState(events, name)
+	return ok && state.Mode == mode
+}
+
+// awaitPinState waits for the pin state event name and returns the state it
+// carries, or false if it does not arrive within modeConfirmTimeout.
+func awaitPinState(events chan *gobot.Event, name string) (client.Pin, bool) {
+	expired := time.After(modeConfirmTimeout)
+	for {
+		select {
+		case evt := <-events:
+			if state, ok := evt.Data.(client.Pin); ok && evt.Name == name {
+				return state, true
+			}
+		case <-expired:
+			return client.Pin{}, false
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorModeRetries(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithModeRetries(2))
+	board := newMockFirmataBoard()
+	a.board = board
+	a.Connect()
+
+	gobottest.Assert(t, a.DigitalWrite("2", 1), nil)
+	gobottest.Assert(t, len(*board.modes), 1)
+
+	// the first change is lost and is sent again
+	lost := 1
+	board.pinStateQuery = func(pin int) client.Pin {
+		state := board.Pins()[pin]
+		if lost > 0 {
+			lost--
+			state.Mode = client.Input
+		}
+		return state
+	}
+	gobottest.Assert(t, a.PwmWrite("3", 10), nil)
+	gobottest.Assert(t, (*board.modes)[1:], [][2]int{{3, client.Pwm}, {3, client.Pwm}})
+}
+
+func TestAdaptorModeRetriesOtherPins(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithModeRetries(2))
+	board := newMockFirmataBoard()
+	a.board = board
+	a.Connect()
+
+	// pin 2 waits for confirmations which never come while pin 3 changes mode
+	board.pinStateSilent = func(pin int) bool {
+		return pin == 2
+	}
+	result := make(chan error)
+	go func() {
+		result <- a.DigitalWrite("2", 1)
+	}()
+	<-time.After(modeRetryDelay)
+
+	start := time.Now()
+	gobottest.Assert(t, a.PwmWrite("3", 10), nil)
+	if elapsed := time.Since(start); elapsed >= modeConfirmTimeout {
+		t.Errorf("mode change of pin 3 took %v waiting for pin 2", elapsed)
+	}
+	gobottest.Assert(t, <-result, ErrModeNotSet)
+}
+
+func TestAdaptorModeRetriesExhausted(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithModeRetries(2))
+	board := newMockFirmataBoard()
+	board.pinStateQuery = func(pin int) client.Pin {
+		return client.Pin{Mode: client.Input}
+	}
+	a.board = board
+	a.Connect()
+
+	gobottest.Assert(t, a.DigitalWrite("2", 1), ErrModeNotSet)
+	gobottest.Assert(t, len(*board.modes), 3)
+}
@@ -0,0 +1,126 @@
+package firmata
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// Neopixel sysex commands, as implemented by the node-pixel firmware.
+const (
+	NeopixelCommand byte = 0x51
+	neopixelConfig  byte = 0x01
+	neopixelShow    byte = 0x02
+	neopixelSet     byte = 0x03
+)
+
+// NeopixelMaxCount is the largest number of pixels a strip may have, as the
+// count is sent in two 7-bit bytes.
+const NeopixelMaxCount = 0x3FFF
+
+// neopixelMaxPin is the highest pin the config message can address.
+const neopixelMaxPin = 0x1F
+
+// Errors
+var (
+	ErrNeopixelNotConfigured = errors.New("neopixel strip is not configured")
+	ErrNeopixelIndex         = errors.New("neopixel index out of range")
+	ErrNeopixelCount         = errors.New("neopixel count must be between 1 and NeopixelMaxCount")
+	ErrNeopixelPin           = errors.New("neopixel pin must be between 0 and 31")
+)
+
+type neopixelStrip struct {
+	colors []uint32
+	dirty  []bool
+	mutex  sync.Mutex
+}
+
+// NeopixelConfig configures a strip of count WS2812 pixels attached to pin.
+// It must be called before NeopixelSet. Returns ErrNeopixelPin for a pin
+// outside 0 to 31, which the firmware cannot address, and ErrNeopixelCount for
+// a count outside 1 to NeopixelMaxCount.
+func (f *Adaptor) NeopixelConfig(pin string, count int) error {
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+	if p < 0 || p > neopixelMaxPin {
+		return ErrNeopixelPin
+	}
+	if count < 1 || count > NeopixelMaxCount {
+		return ErrNeopixelCount
+	}
+
+	if err := f.board.WriteSysex([]byte{NeopixelCommand, neopixelConfig, byte(p),
+		byte(count & 0x7F), byte((count >> 7) & 0x7F)}); err != nil {
+		return err
+	}
+
+	f.neopixel.mutex.Lock()
+	defer f.neopixel.mutex.Unlock()
+	f.neopixel.colors = make([]uint32, count)
+	f.neopixel.dirty = make([]bool, count)
+	return nil
+}
+
+// NeopixelSet sets the color of the pixel at index. The change is buffered
+// and is sent to the board by the next NeopixelShow.
+func (f *Adaptor) NeopixelSet(index int, r, g, b byte) error {
+	f.neopixel.mutex.Lock()
+	defer f.neopixel.mutex.Unlock()
+
+	if f.neopixel.colors == nil {
+		return ErrNeopixelNotConfigured
+	}
+	if index < 0 || index >= len(f.neopixel.colors) {
+		return ErrNeopixelIndex
+	}
+
+	color := uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+	if f.neopixel.colors[index] != color {
+		f.neopixel.colors[index] = color
+		f.neopixel.dirty[index] = true
+	}
+	return nil
+}
+
+// NeopixelShow latches the frame onto the strip. Each pixel changed since the
+// last NeopixelShow is sent in its own set pixel sysex, which keeps every
+// message far below the 64 byte sysex buffer of AVR boards, and the frame is
+// then latched by a show sysex without payload. The messages of the frame are
+// batched, see Batch, so that the whole frame goes out in a single write.
+func (f *Adaptor) NeopixelShow() (err error) {
+	f.neopixel.mutex.Lock()
+	defer f.neopixel.mutex.Unlock()
+
+	if f.neopixel.colors == nil {
+		return ErrNeopixelNotConfigured
+	}
+
+	batchErr := f.Batch(func() {
+		err = f.writeNeopixelFrame()
+	})
+	if err != nil {
+		return err
+	}
+	return batchErr
+}
+
+// writeNeopixelFrame writes the changed pixels and the show sysex. It must be
+// called with the strip mutex held.
+func (f *Adaptor) writeNeopixelFrame() error {
+	for i, color := range f.neopixel.colors {
+		if !f.neopixel.dirty[i] {
+			continue
+		}
+		if err := f.board.WriteSysex([]byte{NeopixelCommand, neopixelSet,
+			byte(i & 0x7F), byte((i >> 7) & 0x7F),
+			byte(color & 0x7F), byte((color >> 7) & 0x7F),
+			byte((color >> 14) & 0x7F), byte((color >> 21) & 0x7F)}); err != nil {
+			return err
+		}
+		f.neopixel.dirty[i] = false
+	}
+
+	return f.board.WriteSysex([]byte{NeopixelCommand, neopixelShow})
+}
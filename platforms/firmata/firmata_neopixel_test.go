@@ -0,0 +1,66 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorNeopixel(t *testing.T) {
+	a := initTestAdaptor()
+	sysex := a.board.(*mockFirmataBoard).sysex
+
+	gobottest.Assert(t, a.NeopixelSet(0, 1, 2, 3), ErrNeopixelNotConfigured)
+	gobottest.Assert(t, a.NeopixelShow(), ErrNeopixelNotConfigured)
+
+	gobottest.Assert(t, a.NeopixelConfig("6", 200), nil)
+	gobottest.Assert(t, (*sysex)[0], []byte{0x51, 0x01, 6, 0x48, 0x01})
+
+	gobottest.Assert(t, a.NeopixelSet(200, 1, 2, 3), ErrNeopixelIndex)
+	gobottest.Assert(t, a.NeopixelSet(1, 0xFF, 0, 0), nil)
+	gobottest.Assert(t, a.NeopixelSet(3, 0, 0, 0x01), nil)
+	gobottest.Assert(t, a.NeopixelShow(), nil)
+	gobottest.Assert(t, len(*sysex), 4)
+	gobottest.Assert(t, (*sysex)[1], []byte{0x51, 0x03, 1, 0, 0, 0, 0x7C, 0x07})
+	gobottest.Assert(t, (*sysex)[2], []byte{0x51, 0x03, 3, 0, 0x01, 0, 0, 0})
+	gobottest.Assert(t, (*sysex)[3], []byte{0x51, 0x02})
+
+	// unchanged pixels are not sent again
+	gobottest.Assert(t, a.NeopixelShow(), nil)
+	gobottest.Assert(t, len(*sysex), 5)
+	gobottest.Assert(t, (*sysex)[4], []byte{0x51, 0x02})
+}
+
+func TestAdaptorNeopixelConfigErrors(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Refute(t, a.NeopixelConfig("a", 1), nil)
+	gobottest.Assert(t, a.NeopixelConfig("32", 1), ErrNeopixelPin)
+	gobottest.Assert(t, a.NeopixelConfig("-1", 1), ErrNeopixelPin)
+	gobottest.Assert(t, a.NeopixelConfig("6", 0), ErrNeopixelCount)
+	gobottest.Assert(t, a.NeopixelConfig("6", -5), ErrNeopixelCount)
+	gobottest.Assert(t, a.NeopixelConfig("6", NeopixelMaxCount+1), ErrNeopixelCount)
+	gobottest.Assert(t, len(*a.board.(*mockFirmataBoard).sysex), 0)
+}
+
+func TestAdaptorNeopixelHighIndex(t *testing.T) {
+	a := initTestAdaptor()
+	sysex := a.board.(*mockFirmataBoard).sysex
+
+	gobottest.Assert(t, a.NeopixelConfig("6", NeopixelMaxCount), nil)
+	gobottest.Assert(t, a.NeopixelSet(NeopixelMaxCount-1, 0xFF, 0xFF, 0xFF), nil)
+	gobottest.Assert(t, a.NeopixelShow(), nil)
+	gobottest.Assert(t, (*sysex)[1], []byte{0x51, 0x03, 0x7E, 0x7F, 0x7F, 0x7F, 0x7F, 0x07})
+	gobottest.Assert(t, (*sysex)[2], []byte{0x51, 0x02})
+}
+
+func TestAdaptorNeopixelShowBatched(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.NeopixelConfig("6", 60), nil)
+	calls := []string{}
+	a.board = batchingBoard{mockFirmataBoard: a.board.(*mockFirmataBoard), calls: &calls}
+
+	gobottest.Assert(t, a.NeopixelSet(1, 0xFF, 0, 0), nil)
+	gobottest.Assert(t, a.NeopixelSet(3, 0, 0, 0x01), nil)
+	gobottest.Assert(t, a.NeopixelShow(), nil)
+	gobottest.Assert(t, calls, []string{"begin", "sysex", "sysex", "sysex", "end"})
+}
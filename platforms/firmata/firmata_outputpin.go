@@ -0,0 +1,46 @@
+package firmata
+
+import "gobot.io/x/gobot/platforms/firmata/client"
+
+// OutputPin is a handle for writing a digital output quickly, for uses such
+// as bit-banging a protocol. The pin is resolved and switched to output mode
+// once, when the handle is created with Adaptor.OutputPin.
+type OutputPin struct {
+	adaptor *Adaptor
+	pin     int
+	invert  bool
+}
+
+// OutputPin switches the pin, named as in Pin, to output mode and returns a
+// handle for writing it. Writes through the handle skip the name lookup and
+// the mode check of DigitalWrite, and are neither queued while disconnected,
+// as WithWriteBuffer does for DigitalWrite, nor waited for by Disconnect.
+// Whether the pin is inverted with SetInverted is taken when the handle is
+// created. If the mode of the pin is changed afterwards, the handle must be
+// created again.
+func (f *Adaptor) OutputPin(pin string) (*OutputPin, error) {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(pin)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = f.ensureMode(p, client.Output); err != nil {
+		return nil, err
+	}
+	return &OutputPin{adaptor: f, pin: p, invert: f.isInverted(p)}, nil
+}
+
+// Number returns the number of the pin on the board.
+func (o *OutputPin) Number() int {
+	return o.pin
+}
+
+// Write sets the pin to level, 0 or 1, as DigitalWrite does.
+func (o *OutputPin) Write(level byte) error {
+	if o.invert {
+		level = invertLevel(level)
+	}
+	return o.adaptor.board.DigitalWrite(o.pin, int(level))
+}
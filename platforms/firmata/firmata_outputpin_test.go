@@ -0,0 +1,46 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorOutputPin(t *testing.T) {
+	a := initTestAdaptor()
+	p, err := a.OutputPin("8")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, p.Number(), 8)
+	gobottest.Assert(t, a.board.Pins()[8].Mode, client.Output)
+
+	gobottest.Assert(t, p.Write(1), nil)
+	gobottest.Assert(t, a.board.Pins()[8].Value, 1)
+	gobottest.Assert(t, p.Write(0), nil)
+	gobottest.Assert(t, a.board.Pins()[8].Value, 0)
+
+	gobottest.Assert(t, a.SetInverted("9", true), nil)
+	p, err = a.OutputPin("9")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, p.Write(1), nil)
+	gobottest.Assert(t, a.board.Pins()[9].Value, 0)
+
+	_, err = a.OutputPin("x")
+	gobottest.Assert(t, err, ErrInvalidPin)
+}
+
+func BenchmarkAdaptorDigitalWrite(b *testing.B) {
+	a := initTestAdaptor()
+	for i := 0; i < b.N; i++ {
+		a.DigitalWrite("8", byte(i&1))
+	}
+}
+
+func BenchmarkAdaptorOutputPinWrite(b *testing.B) {
+	a := initTestAdaptor()
+	p, _ := a.OutputPin("8")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Write(byte(i & 1))
+	}
+}
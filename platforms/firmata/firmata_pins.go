@@ -0,0 +1,277 @@
+package firmata
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Errors
+var (
+	ErrInvalidPin      = errors.New("invalid pin")
+	ErrNotReady        = errors.New("pins are not known until the board has reported its capabilities")
+	ErrUnsupportedMode = errors.New("operation is not supported in the pin's current mode")
+	ErrValueOutOfRange = errors.New("value must be between 0-255")
+	ErrDigitalValue    = errors.New("value of a digital output must be 0 or 1")
+	ErrNoAnalogChannel = errors.New("pin has no analog channel")
+)
+
+// PinState is a snapshot of the state of a pin on the board.
+type PinState struct {
+	// Number is the pin number on the board.
+	Number int
+	// Mode is the current pin mode, such as client.Output.
+	Mode int
+	// Value is the last value written to or reported by the pin.
+	Value int
+	// State is the state reported by the last pin state query.
+	State int
+	// AnalogChannel is the analog channel of the pin, or 127 if it has none.
+	AnalogChannel int
+	// SupportedModes lists the pin modes supported by the pin.
+	SupportedModes []int
+	// Resolutions maps each of SupportedModes to its resolution in bits, as
+	// reported in the capability response, such as 10 for the analog mode of
+	// an Arduino Uno or 14 for its servo mode. The meaning of the resolution
+	// depends on the mode; it is 1 for digital modes.
+	Resolutions map[int]int
+	// Updated is the time the last analog or digital report for the pin was
+	// received, or the zero time if none has been. A report refreshes it even
+	// if the value did not change, so an old time means the pin went silent.
+	Updated time.Time
+}
+
+// Pins returns a snapshot of every pin on the board. The returned states are
+// copies and may be used freely by the caller.
+func (f *Adaptor) Pins() []PinState {
+	f.pinMutex.RLock()
+	defer f.pinMutex.RUnlock()
+
+	pins := f.board.Pins()
+	states := make([]PinState, len(pins))
+	for i := range pins {
+		states[i] = newPinState(i, pins)
+	}
+	return states
+}
+
+// PinCount returns the number of pins listed in the capability response of
+// the board, or 0 before it has been received.
+func (f *Adaptor) PinCount() int {
+	return len(f.board.Pins())
+}
+
+// AnalogPinCount returns the number of pins which the capability response
+// lists with an analog mode, or 0 before it has been received.
+func (f *Adaptor) AnalogPinCount() int {
+	n := 0
+	for _, pin := range f.board.Pins() {
+		if supportsMode(pin, client.Analog) {
+			n++
+		}
+	}
+	return n
+}
+
+// pinReader is implemented by boards which can look up a single pin without
+// copying them all, such as client.Client.
+type pinReader interface {
+	Pin(int) (client.Pin, bool)
+}
+
+// pin returns the state of pin, and whether the board has it.
+func (f *Adaptor) pin(pin int) (client.Pin, bool) {
+	if b, ok := f.board.(pinReader); ok {
+		return b.Pin(pin)
+	}
+	pins := f.board.Pins()
+	if pin < 0 || pin >= len(pins) {
+		return client.Pin{}, false
+	}
+	return pins[pin], true
+}
+
+// pinMismatcher is implemented by boards which detect disagreeing pin counts
+// in their handshake responses, such as client.Client.
+type pinMismatcher interface {
+	PinMismatch() error
+}
+
+// checkPinMismatch reports a board whose capability and analog mapping
+// responses disagree about the number of pins. It is not fatal: the board
+// trusts the capability response, as described by client.PinMismatchError,
+// and the mismatch is published with the "Warning" event and set as the
+// LastError so that the firmware bug does not go unnoticed.
+func (f *Adaptor) checkPinMismatch() {
+	b, ok := f.board.(pinMismatcher)
+	if !ok {
+		return
+	}
+	if err := b.PinMismatch(); err != nil {
+		f.logger.Errorf("firmata: %v", err)
+		f.setLastError(err)
+		f.Publish(f.Event("Warning"), err)
+	}
+}
+
+// Pin returns a snapshot of the named pin. The name may be a pin number such
+// as "13", an analog channel such as "A0", or an alias set with SetPinAlias.
+func (f *Adaptor) Pin(name string) (PinState, error) {
+	f.pinMutex.RLock()
+	defer f.pinMutex.RUnlock()
+
+	p, err := f.resolvePin(name)
+	if err != nil {
+		return PinState{}, err
+	}
+	return newPinState(p, f.board.Pins()), nil
+}
+
+// AnalogChannel returns the analog channel of the pin, named as in Pin, taken
+// from the analog mapping reported by the board, so that tools can translate
+// between pin numbers and channels without assuming an offset. Returns
+// ErrNoAnalogChannel for a pin which has none.
+func (f *Adaptor) AnalogChannel(pin string) (int, error) {
+	state, err := f.Pin(pin)
+	if err != nil {
+		return 0, err
+	}
+	if state.AnalogChannel == 127 {
+		return 0, ErrNoAnalogChannel
+	}
+	return state.AnalogChannel, nil
+}
+
+// SetPinAlias makes alias resolve to pin in Pin.
+func (f *Adaptor) SetPinAlias(alias string, pin string) error {
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+
+	f.pinMutex.Lock()
+	defer f.pinMutex.Unlock()
+	f.aliases[alias] = p
+	return nil
+}
+
+// resolvePin returns the pin number for name. It must be called with pinMutex
+// held.
+func (f *Adaptor) resolvePin(name string) (int, error) {
+	pins := f.board.Pins()
+
+	p, ok := f.aliases[name]
+	if !ok {
+		if strings.HasPrefix(name, "A") {
+			channel, err := strconv.Atoi(name[1:])
+			if err != nil {
+				return 0, ErrInvalidPin
+			}
+			p = -1
+			for i := range pins {
+				if pins[i].AnalogChannel == channel {
+					p = i
+					break
+				}
+			}
+		} else {
+			n, err := strconv.Atoi(name)
+			if err != nil {
+				return 0, ErrInvalidPin
+			}
+			p = n
+		}
+	}
+
+	if len(pins) == 0 {
+		return 0, ErrNotReady
+	}
+	if p < 0 || p >= len(pins) {
+		return 0, ErrInvalidPin
+	}
+	return p, nil
+}
+
+// missingPin returns the error for a pin the board does not have, which is
+// ErrNotReady until the board has reported its pins.
+func (f *Adaptor) missingPin() error {
+	if f.PinCount() == 0 {
+		return ErrNotReady
+	}
+	return ErrInvalidPin
+}
+
+func newPinState(p int, pins []client.Pin) PinState {
+	pin := pins[p]
+	modes := make([]int, len(pin.SupportedModes))
+	copy(modes, pin.SupportedModes)
+	resolutions := make(map[int]int)
+	for i, bits := range pin.Resolutions {
+		if i < len(modes) {
+			resolutions[modes[i]] = bits
+		}
+	}
+
+	return PinState{
+		Number:         p,
+		Mode:           pin.Mode,
+		Value:          pin.Value,
+		State:          pin.State,
+		AnalogChannel:  pin.AnalogChannel,
+		SupportedModes: modes,
+		Resolutions:    resolutions,
+		Updated:        pin.Updated,
+	}
+}
+
+// ReadValue reads the pin using DigitalRead or AnalogRead, depending on its
+// current mode. The pin is named as in Pin. Returns ErrUnsupportedMode for
+// pins in a mode that cannot be read.
+func (f *Adaptor) ReadValue(pin string) (int, error) {
+	state, err := f.Pin(pin)
+	if err != nil {
+		return 0, err
+	}
+
+	switch state.Mode {
+	case client.Input, client.InputPullup:
+		return f.DigitalRead(strconv.Itoa(state.Number))
+	case client.Analog:
+		return f.readAnalog(state.Number, state.AnalogChannel, f.timeouts.AnalogSettle)
+	}
+	return 0, ErrUnsupportedMode
+}
+
+// WriteValue writes value to the pin using DigitalWrite, PwmWrite or
+// ServoWrite, depending on its current mode. The pin is named as in Pin.
+// Returns ErrUnsupportedMode for pins in a mode that cannot be written,
+// ErrDigitalValue for a value other than 0 or 1 written to an output pin, and
+// ErrValueOutOfRange for a value outside 0-255 written to a PWM or servo pin.
+func (f *Adaptor) WriteValue(pin string, value int) error {
+	state, err := f.Pin(pin)
+	if err != nil {
+		return err
+	}
+
+	p := strconv.Itoa(state.Number)
+	if state.Mode == client.Output {
+		if value < 0 || value > 1 {
+			return ErrDigitalValue
+		}
+		return f.DigitalWrite(p, byte(value))
+	}
+	if value < 0 || value > 255 {
+		return ErrValueOutOfRange
+	}
+
+	switch state.Mode {
+	case client.Pwm:
+		return f.PwmWrite(p, byte(value))
+	case client.Servo:
+		return f.ServoWrite(p, byte(value))
+	}
+	return ErrUnsupportedMode
+}
@@ -0,0 +1,194 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorPins(t *testing.T) {
+	a := initTestAdaptor()
+	pins := a.Pins()
+	gobottest.Assert(t, len(pins), 100)
+	gobottest.Assert(t, pins[15].Number, 15)
+	gobottest.Assert(t, pins[15].Value, 133)
+	gobottest.Assert(t, pins[15].Updated.IsZero(), true)
+
+	now := time.Now()
+	a.board.Pins()[15].Updated = now
+	gobottest.Assert(t, a.Pins()[15].Updated, now)
+}
+
+func TestAdaptorPinsCopy(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[15].SupportedModes = []int{client.Input, client.Analog}
+
+	pins := a.Pins()
+	pins[15].Value = 0
+	pins[15].SupportedModes[0] = client.Servo
+	pins[15].Resolutions[client.Servo] = 14
+	pin, _ := a.Pin("15")
+	pin.SupportedModes[1] = client.Servo
+
+	pins = a.Pins()
+	gobottest.Assert(t, pins[15].Value, 133)
+	gobottest.Assert(t, pins[15].SupportedModes, []int{client.Input, client.Analog})
+	gobottest.Assert(t, pins[15].Resolutions, map[int]int{})
+}
+
+func TestAdaptorPinCount(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{})
+	board := newMockFirmataBoard()
+	board.pins = nil
+	a.board = board
+	gobottest.Assert(t, a.PinCount(), 0)
+	gobottest.Assert(t, a.AnalogPinCount(), 0)
+
+	board.pins = make([]client.Pin, 20)
+	for p := 14; p < 20; p++ {
+		board.pins[p].SupportedModes = []int{client.Input, client.Analog}
+	}
+	gobottest.Assert(t, a.PinCount(), 20)
+	gobottest.Assert(t, a.AnalogPinCount(), 6)
+}
+
+func TestAdaptorPin(t *testing.T) {
+	a := initTestAdaptor()
+	for i := range a.board.Pins() {
+		a.board.Pins()[i].AnalogChannel = 127
+	}
+	a.board.Pins()[15].AnalogChannel = 1
+	a.board.Pins()[15].SupportedModes = []int{client.Input, client.Analog}
+	a.board.Pins()[15].Resolutions = []int{1, 10}
+
+	pin, err := a.Pin("15")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, pin.Value, 133)
+	gobottest.Assert(t, pin.SupportedModes, []int{client.Input, client.Analog})
+	gobottest.Assert(t, pin.Resolutions, map[int]int{client.Input: 1, client.Analog: 10})
+
+	pin, err = a.Pin("A1")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, pin.Number, 15)
+
+	gobottest.Assert(t, a.SetPinAlias("sensor", "15"), nil)
+	pin, err = a.Pin("sensor")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, pin.Number, 15)
+
+	for _, name := range []string{"A7", "Ax", "100", "-1", "unknown"} {
+		_, err = a.Pin(name)
+		gobottest.Assert(t, err, ErrInvalidPin)
+	}
+	gobottest.Refute(t, a.SetPinAlias("sensor", "x"), nil)
+}
+
+func TestAdaptorAnalogChannel(t *testing.T) {
+	a := initTestAdaptor()
+	for i := range a.board.Pins() {
+		a.board.Pins()[i].AnalogChannel = 127
+	}
+	a.board.Pins()[54].AnalogChannel = 0
+	a.board.Pins()[55].AnalogChannel = 1
+
+	channel, err := a.AnalogChannel("55")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, channel, 1)
+
+	gobottest.Assert(t, a.SetPinAlias("light", "54"), nil)
+	channel, err = a.AnalogChannel("light")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, channel, 0)
+
+	_, err = a.AnalogChannel("13")
+	gobottest.Assert(t, err, ErrNoAnalogChannel)
+	_, err = a.AnalogChannel("100")
+	gobottest.Assert(t, err, ErrInvalidPin)
+}
+
+func TestAdaptorReadValue(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[1].Mode = client.Input
+	val, err := a.ReadValue("1")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+
+	a.board.Pins()[15].Mode = client.Analog
+	a.board.Pins()[15].AnalogChannel = 1
+	val, err = a.ReadValue("A1")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 133)
+	gobottest.Assert(t, a.readArmed(analogReport, 1), true)
+
+	gobottest.Assert(t, a.SetPinAlias("button", "1"), nil)
+	val, err = a.ReadValue("button")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+
+	a.board.Pins()[2].Mode = client.Servo
+	_, err = a.ReadValue("2")
+	gobottest.Assert(t, err, ErrUnsupportedMode)
+
+	_, err = a.ReadValue("100")
+	gobottest.Assert(t, err, ErrInvalidPin)
+	_, err = a.ReadValue("a")
+	gobottest.Refute(t, err, nil)
+}
+
+func TestAdaptorWriteValue(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[2].Mode = client.Output
+	gobottest.Assert(t, a.WriteValue("2", 1), nil)
+	gobottest.Assert(t, a.board.Pins()[2].Value, 1)
+	err := a.WriteValue("2", 2)
+	gobottest.Assert(t, err, ErrDigitalValue)
+	gobottest.Assert(t, err.Error(), "value of a digital output must be 0 or 1")
+	gobottest.Assert(t, a.WriteValue("2", -1), ErrDigitalValue)
+	gobottest.Assert(t, a.board.Pins()[2].Value, 1)
+
+	a.board.Pins()[3].Mode = client.Pwm
+	gobottest.Assert(t, a.WriteValue("3", 128), nil)
+	a.board.Pins()[4].Mode = client.Servo
+	gobottest.Assert(t, a.WriteValue("4", 90), nil)
+
+	a.board.Pins()[5].Mode = client.Analog
+	gobottest.Assert(t, a.WriteValue("5", 1), ErrUnsupportedMode)
+	gobottest.Assert(t, a.WriteValue("4", 256), ErrValueOutOfRange)
+	gobottest.Assert(t, a.WriteValue("3", 256).Error(), "value must be between 0-255")
+	gobottest.Assert(t, a.WriteValue("100", 1), ErrInvalidPin)
+	gobottest.Refute(t, a.WriteValue("a", 1), nil)
+}
+
+// mismatchBoard is a board whose handshake responses disagreed about the
+// number of pins.
+type mismatchBoard struct {
+	*mockFirmataBoard
+	err error
+}
+
+func (m mismatchBoard) PinMismatch() error { return m.err }
+
+func TestAdaptorPinMismatch(t *testing.T) {
+	a := initTestAdaptor()
+	mismatch := &client.PinMismatchError{Capabilities: 20, AnalogMapping: 18}
+	a.board = mismatchBoard{mockFirmataBoard: newMockFirmataBoard(), err: mismatch}
+
+	warnings := make(chan interface{}, 1)
+	a.On(a.Event("Warning"), func(data interface{}) {
+		warnings <- data
+	})
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, a.LastError(), error(mismatch))
+	select {
+	case data := <-warnings:
+		gobottest.Assert(t, data, error(mismatch))
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Warning was not published")
+	}
+
+	a.board = mismatchBoard{mockFirmataBoard: newMockFirmataBoard()}
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, a.LastError(), nil)
+}
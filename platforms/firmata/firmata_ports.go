@@ -0,0 +1,106 @@
+package firmata
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultProbeTimeout is the default time AutoConnect waits for a board to
+// answer the handshake on each port. It leaves time for the boards which reset
+// when the port is opened to start their firmware.
+const DefaultProbeTimeout = 3 * time.Second
+
+// Errors
+var (
+	ErrNoBoard = errors.New("no board answered the handshake on any serial port")
+)
+
+// listPorts lists the ports AutoConnect tries.
+var listPorts = ListPorts
+
+// serialPortPatterns are the device files of common USB serial adapters, such
+// as the Arduino's own USB interface and FTDI, CH340 and CP210x chips, on
+// each system which names them in /dev.
+var serialPortPatterns = map[string][]string{
+	"linux":   {"/dev/ttyACM*", "/dev/ttyUSB*"},
+	"darwin":  {"/dev/cu.usbmodem*", "/dev/cu.usbserial*", "/dev/cu.wchusbserial*", "/dev/cu.SLAB_USBtoUART*"},
+	"freebsd": {"/dev/cuaU*"},
+}
+
+// ListPorts returns the serial ports a board is likely connected to, for
+// offering a choice of port before calling NewAdaptor. On Linux, macOS and
+// FreeBSD these are the device files of common USB serial adapters, and on
+// Windows the COM ports listed in the registry. Other systems return none. The
+// list is a best guess: a port may belong to another device.
+func ListPorts() ([]string, error) {
+	if runtime.GOOS == "windows" {
+		out, err := exec.Command("reg", "query", `HKLM\HARDWARE\DEVICEMAP\SERIALCOMM`).Output()
+		if err != nil {
+			return nil, err
+		}
+		return parseSerialComm(string(out)), nil
+	}
+	return globPorts(serialPortPatterns[runtime.GOOS])
+}
+
+// globPorts returns the files matching patterns, sorted within each pattern.
+func globPorts(patterns []string) ([]string, error) {
+	ports := []string{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		ports = append(ports, matches...)
+	}
+	return ports, nil
+}
+
+// parseSerialComm returns the COM ports in the output of a reg query of the
+// SERIALCOMM key, whose values each map a device to the name of its port.
+func parseSerialComm(out string) []string {
+	ports := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[1] == "REG_SZ" {
+			ports = append(ports, fields[2])
+		}
+	}
+	return ports
+}
+
+// AutoConnect tries each port returned by ListPorts in turn and returns an
+// Adaptor connected to the first board which completes the handshake. The
+// Adaptors are made with opts, preceded by a handshake timeout of
+// DefaultProbeTimeout which opts may override. The ports of failed attempts
+// are closed again. Returns ErrNoBoard if no board answered.
+func AutoConnect(opts ...Option) (*Adaptor, error) {
+	ports, err := listPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, port := range ports {
+		args := []interface{}{port, WithHandshakeTimeout(DefaultProbeTimeout)}
+		for _, opt := range opts {
+			args = append(args, opt)
+		}
+		f := NewAdaptor(args...)
+		if err := f.Connect(); err != nil {
+			f.logger.Debugf("firmata: no board on %v: %v", port, err)
+			f.Disconnect()
+			if f.conn != nil {
+				f.conn.Close()
+			}
+			continue
+		}
+		return f, nil
+	}
+	return nil, ErrNoBoard
+}
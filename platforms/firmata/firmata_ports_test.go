@@ -0,0 +1,91 @@
+package firmata
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestGlobPorts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "firmata")
+	gobottest.Assert(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"ttyUSB1", "ttyACM0", "ttyUSB0", "ttyS0"} {
+		gobottest.Assert(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600), nil)
+	}
+
+	ports, err := globPorts([]string{filepath.Join(dir, "ttyACM*"), filepath.Join(dir, "ttyUSB*")})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, ports, []string{
+		filepath.Join(dir, "ttyACM0"),
+		filepath.Join(dir, "ttyUSB0"),
+		filepath.Join(dir, "ttyUSB1"),
+	})
+
+	ports, err = globPorts(nil)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, ports, []string{})
+}
+
+func TestParseSerialComm(t *testing.T) {
+	out := "\r\nHKEY_LOCAL_MACHINE\\HARDWARE\\DEVICEMAP\\SERIALCOMM\r\n" +
+		"    \\Device\\Serial0    REG_SZ    COM1\r\n" +
+		"    \\Device\\USBSER000    REG_SZ    COM3\r\n\r\n"
+	gobottest.Assert(t, parseSerialComm(out), []string{"COM1", "COM3"})
+}
+
+func TestAutoConnect(t *testing.T) {
+	defer func(list func() ([]string, error)) { listPorts = list }(listPorts)
+	listPorts = func() ([]string, error) {
+		return []string{"/dev/ttyACM0", "/dev/ttyACM1", "/dev/ttyACM2"}, nil
+	}
+
+	closed := make(chan string, 3)
+	probe := func(f *Adaptor) {
+		port := f.Port()
+		f.openCommPort = func(string) (io.ReadWriteCloser, error) {
+			return &portCloser{port: port, closed: closed}, nil
+		}
+		board := newMockFirmataBoard()
+		board.connect = func() error {
+			if port == "/dev/ttyACM0" {
+				return errors.New("not firmata")
+			}
+			return nil
+		}
+		f.board = board
+	}
+
+	a, err := AutoConnect(probe, WithHandshakeTimeout(time.Second))
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, a.Port(), "/dev/ttyACM1")
+	gobottest.Assert(t, a.timeouts.Handshake, time.Second)
+	gobottest.Assert(t, <-closed, "/dev/ttyACM0")
+	gobottest.Assert(t, len(closed), 0)
+
+	listPorts = func() ([]string, error) { return nil, nil }
+	_, err = AutoConnect()
+	gobottest.Assert(t, err, ErrNoBoard)
+
+	listPorts = func() ([]string, error) { return nil, errors.New("no ports") }
+	_, err = AutoConnect()
+	gobottest.Assert(t, err, errors.New("no ports"))
+}
+
+type portCloser struct {
+	readWriteCloser
+	port   string
+	closed chan string
+}
+
+func (c *portCloser) Close() error {
+	c.closed <- c.port
+	return nil
+}
@@ -0,0 +1,55 @@
+package firmata
+
+import "gobot.io/x/gobot/platforms/firmata/client"
+
+// WithPullupPins configures the pins as inputs with the internal pull-up
+// resistor enabled, and enables their digital reports, each time the Adaptor
+// connects. The pins may be named as in Pin. Connect fails with ErrInvalidPin
+// if one of them does not exist on the board.
+func WithPullupPins(pins ...string) Option {
+	return func(f *Adaptor) {
+		f.pullups = append(f.pullups, pins...)
+	}
+}
+
+// setupPullups applies WithPullupPins after connecting. Reporting is held for
+// the ports of the pins for as long as the Adaptor lives, and is enabled
+// again on every connect by restoreReporting since the board forgets it.
+func (f *Adaptor) setupPullups() error {
+	ports := []int{}
+	for _, pin := range f.pullups {
+		f.pinMutex.RLock()
+		p, err := f.resolvePin(pin)
+		f.pinMutex.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		if _, err = f.ensureMode(p, client.InputPullup); err != nil {
+			return err
+		}
+		if port := p / 8; !containsInt(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+
+	for _, port := range ports {
+		if f.pullupPorts[port] {
+			continue
+		}
+		if _, err := f.acquireReport(digitalReport, port); err != nil {
+			return err
+		}
+		f.pullupPorts[port] = true
+	}
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorWithPullupPins(t *testing.T) {
+	a := initTestAdaptor()
+	WithPullupPins("2", "3", "9")(a)
+	reports := a.board.(*mockFirmataBoard).reports
+
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	for _, p := range []int{2, 3, 9} {
+		gobottest.Assert(t, a.board.Pins()[p].Mode, client.InputPullup)
+	}
+	gobottest.Assert(t, *reports, [][3]int{
+		{int(client.ReportDigital), 0, 1},
+		{int(client.ReportDigital), 1, 1},
+	})
+
+	a.board.Pins()[3].Value = 1
+	val, err := a.DigitalRead("3")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, val, 1)
+	gobottest.Assert(t, a.board.Pins()[3].Mode, client.InputPullup)
+
+	// reporting is enabled again on reconnect, and kept by the Adaptor
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, len(*reports), 4)
+	cancel, _ := a.Watch("2", func(int) {})
+	cancel()
+	gobottest.Assert(t, len(*reports), 4)
+}
+
+func TestAdaptorWithPullupPinsInvalid(t *testing.T) {
+	a := initTestAdaptor()
+	WithPullupPins("x")(a)
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), ErrInvalidPin)
+}
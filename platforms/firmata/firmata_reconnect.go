@@ -0,0 +1,140 @@
+package firmata
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReconnectJitter is the default fraction by which each reconnect delay
+// is randomly shortened or lengthened.
+const DefaultReconnectJitter = 0.2
+
+// WithReconnect makes the Adaptor reconnect on its own when the connection it
+// opened is closed by the board, as when a USB cable is pulled. The first
+// attempt is made after initial, and the delay doubles with each failed
+// attempt up to max. Connections passed to NewAdaptor cannot be reopened, so
+// they are not reconnected. Writes made meanwhile fail, or are queued with
+// WithWriteBuffer. Each successful reconnect publishes the "Reconnect" event
+// with the number of attempts it took.
+func WithReconnect(initial, max time.Duration) Option {
+	return func(f *Adaptor) {
+		f.reconnect.initial = initial
+		f.reconnect.max = max
+	}
+}
+
+// WithReconnectJitter sets the fraction, from 0 to 1, by which each reconnect
+// delay is randomly shortened or lengthened, so that boards which lost their
+// connection together do not all reconnect at the same moment. The default is
+// DefaultReconnectJitter; a fraction of 0 disables the jitter.
+func WithReconnectJitter(fraction float64) Option {
+	return func(f *Adaptor) {
+		if fraction < 0 {
+			fraction = 0
+		}
+		if fraction > 1 {
+			fraction = 1
+		}
+		f.reconnect.jitter = fraction
+	}
+}
+
+type reconnector struct {
+	initial time.Duration
+	max     time.Duration
+	jitter  float64
+	random  func() float64
+	stopped int32
+	done    chan struct{}
+	mutex   sync.Mutex
+}
+
+func newReconnector() *reconnector {
+	return &reconnector{
+		jitter: DefaultReconnectJitter,
+		random: rand.Float64,
+	}
+}
+
+// delay returns the time to wait before the given attempt, counted from 0.
+func (r *reconnector) delay(attempt int) time.Duration {
+	d := r.initial
+	for i := 0; i < attempt && d < r.max; i++ {
+		d *= 2
+	}
+	if d > r.max {
+		d = r.max
+	}
+	return time.Duration(float64(d) * (1 + r.jitter*(2*r.random()-1)))
+}
+
+// startReconnect starts reconnecting in the background, unless reconnecting is
+// disabled or already in progress, or the Adaptor was disconnected on purpose.
+func (f *Adaptor) startReconnect() {
+	r := f.reconnect
+	if r.initial <= 0 || !f.openedConn || atomic.LoadInt32(&r.stopped) != 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.done != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	r.done = done
+	go func() {
+		for attempt := 0; ; attempt++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(r.delay(attempt)):
+			}
+
+			r.mutex.Lock()
+			if r.done != done {
+				r.mutex.Unlock()
+				return
+			}
+			err := f.reopen()
+			if err == nil {
+				r.done = nil
+			}
+			r.mutex.Unlock()
+
+			if err == nil {
+				f.logger.Infof("firmata: reconnected to %v after %v attempts", f.Label(), attempt+1)
+				f.Publish(f.Event("Reconnect"), attempt+1)
+				return
+			}
+			f.logger.Errorf("firmata: reconnecting to %v: %v", f.Label(), err)
+		}
+	}()
+}
+
+// stopReconnect stops reconnecting in the background, and keeps a connection
+// closed afterwards from being reconnected until the next Connect. An attempt
+// in progress is completed first.
+func (f *Adaptor) stopReconnect() {
+	r := f.reconnect
+	atomic.StoreInt32(&r.stopped, 1)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+}
+
+// reopen closes the connection lost by the board and connects again through a
+// newly opened one.
+func (f *Adaptor) reopen() error {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+	return f.Connect()
+}
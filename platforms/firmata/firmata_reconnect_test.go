@@ -0,0 +1,113 @@
+package firmata
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestReconnectorDelay(t *testing.T) {
+	r := newReconnector()
+	r.initial = 10 * time.Millisecond
+	r.max = 50 * time.Millisecond
+
+	r.random = func() float64 { return 0.5 }
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for attempt, d := range expected {
+		gobottest.Assert(t, r.delay(attempt), d*time.Millisecond)
+	}
+
+	r.random = func() float64 { return 0 }
+	gobottest.Assert(t, r.delay(1), 16*time.Millisecond)
+	r.random = func() float64 { return 1 }
+	gobottest.Assert(t, r.delay(1), 24*time.Millisecond)
+
+	WithReconnectJitter(0)(&Adaptor{reconnect: r})
+	gobottest.Assert(t, r.delay(1), 20*time.Millisecond)
+}
+
+func TestWithReconnectJitterClamps(t *testing.T) {
+	a := NewAdaptor(WithReconnectJitter(-1))
+	gobottest.Assert(t, a.reconnect.jitter, 0.0)
+	a = NewAdaptor(WithReconnectJitter(2))
+	gobottest.Assert(t, a.reconnect.jitter, 1.0)
+	a = NewAdaptor()
+	gobottest.Assert(t, a.reconnect.jitter, DefaultReconnectJitter)
+}
+
+// newReconnectAdaptor returns a connected Adaptor set up to reconnect, and a
+// channel receiving each port it opens.
+func newReconnectAdaptor() (*Adaptor, *mockFirmataBoard, chan string) {
+	a := NewAdaptor("/dev/null", WithReconnect(time.Millisecond, 5*time.Millisecond))
+	board := newMockFirmataBoard()
+	a.board = board
+	opened := make(chan string, 10)
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		opened <- port
+		return &readWriteCloser{}, nil
+	}
+	a.Connect()
+	<-opened
+	return a, board, opened
+}
+
+func TestAdaptorReconnect(t *testing.T) {
+	a, board, opened := newReconnectAdaptor()
+	reconnected := make(chan interface{}, 1)
+	a.Once(a.Event("Reconnect"), func(data interface{}) {
+		reconnected <- data
+	})
+
+	board.disconnected = true
+	board.Publish("Disconnect", io.EOF)
+
+	select {
+	case port := <-opened:
+		gobottest.Assert(t, port, "/dev/null")
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("port was not opened again")
+	}
+	select {
+	case attempts := <-reconnected:
+		gobottest.Assert(t, attempts, 1)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Reconnect was not published")
+	}
+}
+
+func TestAdaptorReconnectAfterDisconnect(t *testing.T) {
+	a, board, opened := newReconnectAdaptor()
+	gobottest.Assert(t, a.Disconnect(), nil)
+
+	board.disconnected = true
+	board.Publish("Disconnect", io.EOF)
+
+	select {
+	case <-opened:
+		t.Errorf("port was opened again after Disconnect")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestAdaptorReconnectSuppliedConn(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithReconnect(time.Millisecond, time.Millisecond))
+	board := newMockFirmataBoard()
+	a.board = board
+	opened := make(chan string, 1)
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		opened <- port
+		return &readWriteCloser{}, nil
+	}
+	a.Connect()
+
+	board.disconnected = true
+	board.Publish("Disconnect", io.EOF)
+
+	select {
+	case <-opened:
+		t.Errorf("a connection passed to NewAdaptor was reopened")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,44 @@
+package firmata
+
+import "strconv"
+
+// WithActiveHighRelays makes RelayOn drive relay pins high and RelayOff drive
+// them low, for relay modules which switch on a high input. By default relays
+// are taken to be active-low, as most modules are.
+func WithActiveHighRelays() Option {
+	return func(f *Adaptor) {
+		f.activeHigh = true
+	}
+}
+
+// RelayOn switches on the relay driven by the pin, named as in DigitalWrite,
+// by driving the pin low, or high with WithActiveHighRelays. The pin is
+// switched to output mode on first use. The level is electrical: a pin marked
+// with SetInverted is not inverted again.
+func (f *Adaptor) RelayOn(pin string) error {
+	return f.writeRelay(pin, true)
+}
+
+// RelayOff switches off the relay driven by the pin, as RelayOn switches it
+// on.
+func (f *Adaptor) RelayOff(pin string) error {
+	return f.writeRelay(pin, false)
+}
+
+// writeRelay drives the relay pin to the level which switches it on or off.
+// The level is inverted in advance for pins marked with SetInverted, so that
+// DigitalWrite puts it on the line as it is.
+func (f *Adaptor) writeRelay(pin string, on bool) error {
+	level := byte(0)
+	if on == f.activeHigh {
+		level = 1
+	}
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+	if f.isInverted(p) {
+		level = invertLevel(level)
+	}
+	return f.DigitalWrite(pin, level)
+}
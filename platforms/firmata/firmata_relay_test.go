@@ -0,0 +1,36 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorRelay(t *testing.T) {
+	a := initTestAdaptor()
+	modes := a.board.(*mockFirmataBoard).modes
+
+	gobottest.Assert(t, a.RelayOn("7"), nil)
+	gobottest.Assert(t, a.board.Pins()[7].Value, 0)
+	gobottest.Assert(t, a.RelayOff("7"), nil)
+	gobottest.Assert(t, a.board.Pins()[7].Value, 1)
+	gobottest.Assert(t, *modes, [][2]int{{7, client.Output}})
+
+	// the level is not inverted again for an inverted pin
+	gobottest.Assert(t, a.SetInverted("7", true), nil)
+	gobottest.Assert(t, a.RelayOn("7"), nil)
+	gobottest.Assert(t, a.board.Pins()[7].Value, 0)
+
+	gobottest.Refute(t, a.RelayOn("x"), nil)
+}
+
+func TestAdaptorActiveHighRelays(t *testing.T) {
+	a := initTestAdaptor()
+	WithActiveHighRelays()(a)
+
+	gobottest.Assert(t, a.RelayOn("7"), nil)
+	gobottest.Assert(t, a.board.Pins()[7].Value, 1)
+	gobottest.Assert(t, a.RelayOff("7"), nil)
+	gobottest.Assert(t, a.board.Pins()[7].Value, 0)
+}
@@ -0,0 +1,304 @@
+package firmata
+
+import (
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// DefaultReadLinger is the default time reporting enabled by reads stays on
+// after the last read of the channel or port.
+const DefaultReadLinger = 10 * time.Second
+
+// reportKind distinguishes analog reporting, which is enabled per analog
+// channel, from digital reporting, which is enabled per port of eight pins.
+type reportKind int
+
+const (
+	analogReport reportKind = iota
+	digitalReport
+)
+
+type reportKey struct {
+	kind  reportKind
+	index int
+}
+
+// reporting counts the users of each analog channel and digital port, so that
+// reporting is enabled for the first and disabled after the last. Reads hold
+// a single use of the channels and ports they enable, which ends once no read
+// has been made for the linger time, or with the next reset. Each reset
+// starts a new generation, so that the uses it ended are not released again by
+// their holders.
+type reporting struct {
+	counts     map[reportKey]int
+	reads      map[reportKey]*readUse
+	generation int
+	mutex      sync.Mutex
+}
+
+// readUse is the use of a channel or port held by reads.
+type readUse struct {
+	last  time.Time
+	timer *time.Timer
+}
+
+func newReporting() *reporting {
+	return &reporting{
+		counts: make(map[reportKey]int),
+		reads:  make(map[reportKey]*readUse),
+	}
+}
+
+// WithReadLinger sets how long reporting enabled by DigitalRead, AnalogRead
+// and ReadAllAnalog stays on after the last read of the channel or port, so
+// that reads in quick succession return the latest report at once while a
+// one-off read does not keep the board streaming for the rest of the
+// connection. The next read after reporting was disabled enables it again and
+// waits for a report. A zero or negative linger keeps reporting on for good.
+func WithReadLinger(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.readLinger = d
+	}
+}
+
+// acquireReport enables reporting for the channel or port if it has no other
+// users, and returns a function which ends this use. The function may be
+// called more than once, and does nothing once a reset has ended the use.
+func (f *Adaptor) acquireReport(kind reportKind, index int) (release func() error, err error) {
+	return f.takeReport(kind, index, false)
+}
+
+// refreshReport is acquireReport, but enables reporting even if the channel or
+// port has other users, which makes the firmware send its current values. The
+// message is sent once either way.
+func (f *Adaptor) refreshReport(kind reportKind, index int) (release func() error, err error) {
+	return f.takeReport(kind, index, true)
+}
+
+func (f *Adaptor) takeReport(kind reportKind, index int, refresh bool) (release func() error, err error) {
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := reportKey{kind, index}
+	if r.counts[key] == 0 || refresh {
+		if err := f.report(kind, index, 1); err != nil {
+			return nil, err
+		}
+	}
+	r.counts[key]++
+
+	generation := r.generation
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			err = f.releaseReport(kind, index, generation)
+		})
+		return
+	}, nil
+}
+
+// releaseReport disables reporting for the channel or port once its last user
+// is gone. A use acquired before the last reset is already gone.
+func (f *Adaptor) releaseReport(kind reportKind, index int, generation int) error {
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := reportKey{kind, index}
+	if generation != r.generation || r.counts[key] == 0 {
+		return nil
+	}
+	r.counts[key]--
+	if r.counts[key] > 0 {
+		return nil
+	}
+	delete(r.counts, key)
+	return f.report(kind, index, 0)
+}
+
+// IsReporting reports whether the board is currently asked to report the pin,
+// named as in Pin: through its analog channel for a pin in analog mode, and
+// through its digital port otherwise. Reporting is enabled by reads, watches
+// and subscriptions, and stays on while any of them needs it. Returns false
+// for a pin the board does not have.
+func (f *Adaptor) IsReporting(pin string) bool {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(pin)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return false
+	}
+
+	key := reportKey{digitalReport, p / 8}
+	if state, _ := f.pin(p); state.Mode == client.Analog {
+		key = reportKey{analogReport, state.AnalogChannel}
+	}
+
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.counts[key] > 0
+}
+
+// readArmed reports whether a read has already enabled reporting for the
+// channel or port, and if so counts as a read of it.
+func (f *Adaptor) readArmed(kind reportKind, index int) bool {
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	use, ok := r.reads[reportKey{kind, index}]
+	if ok {
+		use.last = time.Now()
+	}
+	return ok
+}
+
+// armRead takes the use of the channel or port held by reads, enabling
+// reporting if needed, and counts as a read of it. The use ends once no read
+// has been made for the linger time set with WithReadLinger. Returns whether
+// the use was taken by this call.
+func (f *Adaptor) armRead(kind reportKind, index int) (bool, error) {
+	return f.armReads(kind, index, f.readLinger)
+}
+
+// holdRead is armRead for a use which does not end for lack of reads.
+func (f *Adaptor) holdRead(kind reportKind, index int) error {
+	_, err := f.armReads(kind, index, 0)
+	return err
+}
+
+// armReads takes the use of the channel or port held by reads, which ends
+// after linger without reads, or never if linger is not positive.
+func (f *Adaptor) armReads(kind reportKind, index int, linger time.Duration) (bool, error) {
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := reportKey{kind, index}
+	if use, ok := r.reads[key]; ok {
+		use.last = time.Now()
+		if linger <= 0 && use.timer != nil {
+			use.timer.Stop()
+			use.timer = nil
+		}
+		return false, nil
+	}
+	if r.counts[key] == 0 {
+		if err := f.report(kind, index, 1); err != nil {
+			return false, err
+		}
+	}
+	r.counts[key]++
+
+	use := &readUse{last: time.Now()}
+	if linger > 0 {
+		use.timer = time.AfterFunc(linger, func() {
+			f.expireRead(key, use, linger)
+		})
+	}
+	r.reads[key] = use
+	return true, nil
+}
+
+// expireRead ends the use held by reads once linger has passed since the last
+// read, disabling reporting unless others need it.
+func (f *Adaptor) expireRead(key reportKey, use *readUse, linger time.Duration) {
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.reads[key] != use || use.timer == nil {
+		return
+	}
+	if idle := time.Since(use.last); idle < linger {
+		use.timer.Reset(linger - idle)
+		return
+	}
+	if err := f.endRead(key); err != nil {
+		f.logger.Debugf("firmata: disabling reporting after the last read: %v", err)
+	}
+}
+
+// disarmRead ends the use of the channel or port held by reads right away,
+// disabling reporting unless others need it. It undoes an armRead whose read
+// did not go ahead.
+func (f *Adaptor) disarmRead(kind reportKind, index int) error {
+	r := f.reporting
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := reportKey{kind, index}
+	use, ok := r.reads[key]
+	if !ok {
+		return nil
+	}
+	if use.timer != nil {
+		use.timer.Stop()
+	}
+	return f.endRead(key)
+}
+
+// endRead drops the use held by reads of key. It must be called with the
+// reporting mutex held.
+func (f *Adaptor) endRead(key reportKey) error {
+	r := f.reporting
+	delete(r.reads, key)
+	r.counts[key]--
+	if r.counts[key] > 0 {
+		return nil
+	}
+	delete(r.counts, key)
+	return f.report(key.kind, key.index, 0)
+}
+
+func (f *Adaptor) report(kind reportKind, index int, state int) error {
+	if kind == analogReport {
+		return f.board.ReportAnalog(index, state)
+	}
+	return f.board.ReportDigital(index, state)
+}
+
+// restoreReporting enables reporting again for every channel and port which
+// has users, as the board forgets it when the connection is lost. It is
+// called on Connect, and publishes the number of channels and ports restored
+// with the "ReportingRestored" event if there were any.
+func (f *Adaptor) restoreReporting() error {
+	r := f.reporting
+	r.mutex.Lock()
+	restored := 0
+	for key, count := range r.counts {
+		if count == 0 {
+			continue
+		}
+		if err := f.report(key.kind, key.index, 1); err != nil {
+			r.mutex.Unlock()
+			return err
+		}
+		restored++
+	}
+	r.mutex.Unlock()
+
+	if restored > 0 {
+		f.Publish(f.Event("ReportingRestored"), restored)
+	}
+	return nil
+}
+
+// reset forgets every user, after reporting was disabled for all pins, so
+// that the next read enables reporting again.
+func (r *reporting) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, use := range r.reads {
+		if use.timer != nil {
+			use.timer.Stop()
+		}
+	}
+	r.counts = make(map[reportKey]int)
+	r.reads = make(map[reportKey]*readUse)
+	r.generation++
+}
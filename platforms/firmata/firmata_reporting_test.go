@@ -0,0 +1,73 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorIsReporting(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.IsReporting("10"), false)
+
+	cancel, err := a.Watch("10", func(int) {})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, a.IsReporting("10"), true)
+	// the whole port is reported
+	gobottest.Assert(t, a.IsReporting("11"), true)
+	gobottest.Assert(t, a.IsReporting("2"), false)
+	cancel()
+	gobottest.Assert(t, a.IsReporting("10"), false)
+
+	a.board.Pins()[16].Mode = client.Analog
+	a.board.Pins()[16].AnalogChannel = 2
+	gobottest.Assert(t, a.IsReporting("16"), false)
+	_, err = a.AnalogRead("2")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, a.IsReporting("16"), true)
+
+	gobottest.Assert(t, a.IsReporting("x"), false)
+	gobottest.Assert(t, a.IsReporting("100"), false)
+}
+
+func TestAdaptorReadLinger(t *testing.T) {
+	a := initTestAdaptor()
+	WithReadLinger(50 * time.Millisecond)(a)
+	WithAnalogSettle(time.Millisecond)(a)
+	board := a.board.(*mockFirmataBoard)
+	a.board.Pins()[16].Mode = client.Analog
+	a.board.Pins()[16].AnalogChannel = 2
+	*board.reports = nil
+	reports := func() [][3]int {
+		board.mutex.Lock()
+		defer board.mutex.Unlock()
+		return append([][3]int(nil), *board.reports...)
+	}
+
+	_, err := a.AnalogRead("2")
+	gobottest.Assert(t, err, nil)
+	<-time.After(30 * time.Millisecond)
+	// reading again keeps reporting on
+	_, err = a.AnalogRead("2")
+	gobottest.Assert(t, err, nil)
+	<-time.After(30 * time.Millisecond)
+	gobottest.Assert(t, a.IsReporting("16"), true)
+	gobottest.Assert(t, reports(), [][3]int{{int(client.ReportAnalog), 2, 1}})
+
+	<-time.After(50 * time.Millisecond)
+	gobottest.Assert(t, a.IsReporting("16"), false)
+	gobottest.Assert(t, reports(), [][3]int{
+		{int(client.ReportAnalog), 2, 1}, {int(client.ReportAnalog), 2, 0},
+	})
+
+	// the next read enables reporting again, and SetAnalogInput keeps it on
+	_, err = a.AnalogRead("2")
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, a.IsReporting("16"), true)
+	gobottest.Assert(t, a.SetAnalogInput("2"), nil)
+	<-time.After(80 * time.Millisecond)
+	gobottest.Assert(t, a.IsReporting("16"), true)
+	gobottest.Assert(t, len(reports()), 3)
+}
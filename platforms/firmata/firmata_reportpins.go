@@ -0,0 +1,105 @@
+package firmata
+
+import (
+	"sync"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// reportPins holds the pins of WithReportPins, and the uses of their
+// reporting taken by the first connect.
+type reportPins struct {
+	analog   []string
+	digital  []string
+	releases []func() error
+	mutex    sync.Mutex
+}
+
+// WithReportPins makes the Adaptor enable reporting for the pins when it
+// connects, so that their values are delivered to OnAnalogRead and
+// OnDigitalRead handlers from the start. Analog pins are named as in
+// AnalogRead and switched to analog mode; digital pins are named as in Pin and
+// switched to input mode unless they already are inputs. Reporting is held as
+// a watch would hold it, so ending a watch or subscription of one of the pins
+// leaves it on until StopReportPins is called. Connect fails with
+// ErrInvalidPin if one of the pins does not exist on the board.
+func WithReportPins(analog []string, digital []string) Option {
+	return func(f *Adaptor) {
+		f.reportPins.analog = append(f.reportPins.analog, analog...)
+		f.reportPins.digital = append(f.reportPins.digital, digital...)
+	}
+}
+
+// StopReportPins ends the reporting WithReportPins holds for its pins,
+// disabling it for those which no read, watch or subscription needs, and
+// keeps later connects from enabling it again.
+func (f *Adaptor) StopReportPins() (err error) {
+	f.reportPins.mutex.Lock()
+	defer f.reportPins.mutex.Unlock()
+
+	for _, release := range f.reportPins.releases {
+		if e := release(); e != nil && err == nil {
+			err = e
+		}
+	}
+	f.reportPins.releases = nil
+	f.reportPins.analog = nil
+	f.reportPins.digital = nil
+	return err
+}
+
+// setupReportPins applies WithReportPins after connecting. Reporting is only
+// acquired on the first connect, and is enabled again on the following ones
+// by restoreReporting since the board forgets it.
+func (f *Adaptor) setupReportPins() error {
+	f.reportPins.mutex.Lock()
+	defer f.reportPins.mutex.Unlock()
+
+	if f.reportPins.releases != nil {
+		return nil
+	}
+	releases := []func() error{}
+	fail := func(err error) error {
+		for _, release := range releases {
+			release()
+		}
+		return err
+	}
+
+	for _, pin := range f.reportPins.analog {
+		p, channel, err := f.analogPin(pin)
+		if err != nil {
+			return fail(ErrInvalidPin)
+		}
+		if _, err = f.ensureMode(p, client.Analog); err != nil {
+			return fail(err)
+		}
+		release, err := f.acquireReport(analogReport, channel)
+		if err != nil {
+			return fail(err)
+		}
+		releases = append(releases, release)
+	}
+
+	for _, pin := range f.reportPins.digital {
+		f.pinMutex.RLock()
+		p, err := f.resolvePin(pin)
+		f.pinMutex.RUnlock()
+		if err != nil {
+			return fail(err)
+		}
+		if _, err = f.ensureMode(p, client.Input); err != nil {
+			return fail(err)
+		}
+		release, err := f.acquireReport(digitalReport, p/8)
+		if err != nil {
+			return fail(err)
+		}
+		releases = append(releases, release)
+	}
+
+	if len(releases) > 0 {
+		f.reportPins.releases = releases
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorWithReportPins(t *testing.T) {
+	a := initTestAdaptor()
+	WithReportPins([]string{"1"}, []string{"9", "10"})(a)
+	reports := a.board.(*mockFirmataBoard).reports
+
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, a.board.Pins()[15].Mode, client.Analog)
+	gobottest.Assert(t, a.board.Pins()[9].Mode, client.Input)
+	gobottest.Assert(t, *reports, [][3]int{
+		{int(client.ReportAnalog), 1, 1},
+		{int(client.ReportDigital), 1, 1},
+	})
+	gobottest.Assert(t, a.IsReporting("10"), true)
+
+	// reporting is enabled again on reconnect, and outlasts a watch
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, len(*reports), 4)
+	cancel, _ := a.Watch("9", func(int) {})
+	cancel()
+	gobottest.Assert(t, len(*reports), 4)
+
+	gobottest.Assert(t, a.StopReportPins(), nil)
+	gobottest.Assert(t, (*reports)[4:], [][3]int{
+		{int(client.ReportAnalog), 1, 0},
+		{int(client.ReportDigital), 1, 0},
+	})
+	gobottest.Assert(t, a.IsReporting("10"), false)
+
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, len(*reports), 6)
+}
+
+func TestAdaptorWithReportPinsInvalid(t *testing.T) {
+	a := initTestAdaptor()
+	WithReportPins([]string{"0"}, []string{"x"})(a)
+	reports := a.board.(*mockFirmataBoard).reports
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), ErrInvalidPin)
+
+	// the reporting enabled before the failure is released
+	gobottest.Assert(t, *reports, [][3]int{
+		{int(client.ReportAnalog), 0, 1},
+		{int(client.ReportAnalog), 0, 0},
+	})
+}
@@ -0,0 +1,139 @@
+package firmata
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Scheduler sysex commands, as implemented by FirmataScheduler in
+// ConfigurableFirmata.
+const (
+	SchedulerCommand  byte = 0x7B
+	schedulerCreate   byte = 0x00
+	schedulerDelete   byte = 0x01
+	schedulerAdd      byte = 0x02
+	schedulerDelay    byte = 0x03
+	schedulerSchedule byte = 0x04
+	schedulerReset    byte = 0x07
+)
+
+// schedulerMaxTaskID is the highest task id the messages can carry.
+const schedulerMaxTaskID = 0x7F
+
+// TaskMaxLength is the largest number of bytes a task may hold, as the length
+// is sent in two 7-bit bytes.
+const TaskMaxLength = 0x3FFF
+
+// taskChunk is the number of task bytes sent with each add message, small
+// enough for the encoded message to fit the sysex buffer of AVR boards.
+const taskChunk = 32
+
+// Errors
+var (
+	ErrTaskID     = errors.New("task id must be between 0 and 127")
+	ErrTaskLength = errors.New("task must hold between 1 and TaskMaxLength bytes")
+	ErrTaskDelay  = errors.New("task delay must not be negative")
+)
+
+// CreateTask creates the task id on the board, holding commands. The commands
+// are Firmata messages, such as digital writes, which the board runs as if
+// received from the host each time the task runs; TaskDelay can be included
+// to pause in between. Creating a task which already exists fails on the
+// board, so it must be deleted first. The task runs once ScheduleTask is
+// called.
+func (f *Adaptor) CreateTask(id int, commands []byte) error {
+	if id < 0 || id > schedulerMaxTaskID {
+		return ErrTaskID
+	}
+	if len(commands) == 0 || len(commands) > TaskMaxLength {
+		return ErrTaskLength
+	}
+
+	if err := f.board.WriteSysex([]byte{SchedulerCommand, schedulerCreate, byte(id),
+		byte(len(commands) & 0x7F), byte((len(commands) >> 7) & 0x7F)}); err != nil {
+		return err
+	}
+	for start := 0; start < len(commands); start += taskChunk {
+		end := start + taskChunk
+		if end > len(commands) {
+			end = len(commands)
+		}
+		msg := append([]byte{SchedulerCommand, schedulerAdd, byte(id)}, encode7Bit(commands[start:end])...)
+		if err := f.board.WriteSysex(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScheduleTask runs the task id on the board once delayMs milliseconds have
+// passed. A task which ends with TaskDelay is run again after that delay, so
+// that it repeats without the host.
+func (f *Adaptor) ScheduleTask(id int, delayMs int) error {
+	if id < 0 || id > schedulerMaxTaskID {
+		return ErrTaskID
+	}
+	if delayMs < 0 {
+		return ErrTaskDelay
+	}
+	return f.board.WriteSysex(append([]byte{SchedulerCommand, schedulerSchedule, byte(id)},
+		encodeTaskTime(delayMs)...))
+}
+
+// DeleteTask stops and deletes the task id on the board.
+func (f *Adaptor) DeleteTask(id int) error {
+	if id < 0 || id > schedulerMaxTaskID {
+		return ErrTaskID
+	}
+	return f.board.WriteSysex([]byte{SchedulerCommand, schedulerDelete, byte(id)})
+}
+
+// ResetScheduler deletes every task on the board.
+func (f *Adaptor) ResetScheduler() error {
+	return f.board.WriteSysex([]byte{SchedulerCommand, schedulerReset})
+}
+
+// TaskDelay returns the command which, within a task, pauses it for delayMs
+// milliseconds. At the end of a task it makes the task repeat after the delay.
+func TaskDelay(delayMs int) []byte {
+	return append([]byte{client.StartSysex, SchedulerCommand, schedulerDelay},
+		append(encodeTaskTime(delayMs), client.EndSysex)...)
+}
+
+// encodeTaskTime encodes milliseconds as the 32-bit little endian time the
+// scheduler messages carry.
+func encodeTaskTime(ms int) []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(ms))
+	return encode7Bit(buf)
+}
+
+// encode7Bit packs data into 7-bit bytes, as Firmata's Encoder7Bit does, with
+// the bits of each byte following those of the byte before it.
+func encode7Bit(data []byte) []byte {
+	out := []byte{}
+	var previous byte
+	shift := uint(0)
+	for _, b := range data {
+		if shift == 0 {
+			out = append(out, b&0x7F)
+			shift++
+			previous = b >> 7
+			continue
+		}
+		out = append(out, (b<<shift)&0x7F|previous)
+		if shift == 6 {
+			out = append(out, b>>1)
+			shift = 0
+		} else {
+			shift++
+			previous = b >> (8 - shift)
+		}
+	}
+	if shift > 0 {
+		out = append(out, previous)
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorCreateTask(t *testing.T) {
+	a := initTestAdaptor()
+	sysex := a.board.(*mockFirmataBoard).sysex
+	before := len(*sysex)
+
+	gobottest.Assert(t, a.CreateTask(3, []byte{0x91, 0x01, 0x00}), nil)
+	gobottest.Assert(t, (*sysex)[before:], [][]byte{
+		{SchedulerCommand, schedulerCreate, 3, 3, 0},
+		{SchedulerCommand, schedulerAdd, 3, 0x11, 0x03, 0x00, 0x00},
+	})
+
+	before = len(*sysex)
+	gobottest.Assert(t, a.CreateTask(4, make([]byte, 40)), nil)
+	gobottest.Assert(t, len((*sysex)[before:]), 3)
+	gobottest.Assert(t, (*sysex)[before][3:], []byte{40, 0})
+
+	gobottest.Assert(t, a.CreateTask(128, []byte{1}), ErrTaskID)
+	gobottest.Assert(t, a.CreateTask(1, nil), ErrTaskLength)
+	gobottest.Assert(t, a.CreateTask(1, make([]byte, TaskMaxLength+1)), ErrTaskLength)
+}
+
+func TestAdaptorScheduleTask(t *testing.T) {
+	a := initTestAdaptor()
+	sysex := a.board.(*mockFirmataBoard).sysex
+
+	gobottest.Assert(t, a.ScheduleTask(3, 1000), nil)
+	gobottest.Assert(t, (*sysex)[len(*sysex)-1],
+		[]byte{SchedulerCommand, schedulerSchedule, 3, 0x68, 0x07, 0, 0, 0})
+	gobottest.Assert(t, a.ScheduleTask(3, -1), ErrTaskDelay)
+	gobottest.Assert(t, a.ScheduleTask(-1, 0), ErrTaskID)
+
+	gobottest.Assert(t, a.DeleteTask(3), nil)
+	gobottest.Assert(t, (*sysex)[len(*sysex)-1], []byte{SchedulerCommand, schedulerDelete, 3})
+	gobottest.Assert(t, a.DeleteTask(200), ErrTaskID)
+
+	gobottest.Assert(t, a.ResetScheduler(), nil)
+	gobottest.Assert(t, (*sysex)[len(*sysex)-1], []byte{SchedulerCommand, schedulerReset})
+}
+
+func TestTaskDelay(t *testing.T) {
+	gobottest.Assert(t, TaskDelay(1000),
+		[]byte{0xF0, SchedulerCommand, schedulerDelay, 0x68, 0x07, 0, 0, 0, 0xF7})
+}
+
+func TestEncode7Bit(t *testing.T) {
+	gobottest.Assert(t, encode7Bit([]byte{0xFF}), []byte{0x7F, 0x01})
+	gobottest.Assert(t, encode7Bit(make([]byte, 7)), make([]byte, 8))
+	gobottest.Assert(t, encode7Bit([]byte{0, 0, 0, 0, 0, 0, 0xFF}), []byte{0, 0, 0, 0, 0, 0, 0x40, 0x7F})
+}
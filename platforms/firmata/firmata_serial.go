@@ -0,0 +1,109 @@
+package firmata
+
+import (
+	"errors"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Serial ports of the board, as numbered by the Serial feature of
+// ConfigurableFirmata.
+const (
+	HardwareSerial0 = 0x00
+	HardwareSerial1 = 0x01
+	HardwareSerial2 = 0x02
+	HardwareSerial3 = 0x03
+	SoftwareSerial0 = 0x08
+	SoftwareSerial1 = 0x09
+	SoftwareSerial2 = 0x0A
+	SoftwareSerial3 = 0x0B
+)
+
+// serialMaxBaud is the largest baud rate the config message carries, in three
+// 7-bit bytes.
+const serialMaxBaud = 1<<21 - 1
+
+// Errors
+var (
+	ErrSerialPort = errors.New("serial port must be between 0 and 15")
+	ErrSerialBaud = errors.New("serial baud rate is out of range")
+	ErrSerialPins = errors.New("software serial ports need both an rx and a tx pin")
+)
+
+// serialBoard is implemented by boards which can use the serial ports of the
+// board, such as client.Client.
+type serialBoard interface {
+	SerialConfig(int, int, int, int) error
+	SerialWrite(int, []byte) error
+	SerialRead(int, int) error
+}
+
+// SerialData is the data published with the Adaptor's "SerialRead" event.
+type SerialData struct {
+	// Port is the serial port of the board the data was read from.
+	Port int
+	// Data holds the bytes read.
+	Data []byte
+}
+
+// SerialConfig opens the serial port portID of the board, such as
+// HardwareSerial1 or SoftwareSerial0, with the given baud rate, and starts
+// reading it. Software serial ports need the rxPin and txPin, named as in Pin,
+// which hardware serial ports have fixed and leave empty. Everything the
+// board reads from the port is published with the "SerialRead" event as
+// SerialData. It needs ConfigurableFirmata with the Serial feature, and
+// returns ErrNotSupported if the board does not implement serial ports.
+func (f *Adaptor) SerialConfig(portID int, baud int, rxPin, txPin string) error {
+	b, ok := f.board.(serialBoard)
+	if !ok {
+		return ErrNotSupported
+	}
+	if portID < 0 || portID > 0x0F {
+		return ErrSerialPort
+	}
+	if baud <= 0 || baud > serialMaxBaud {
+		return ErrSerialBaud
+	}
+
+	rx, tx := -1, -1
+	if rxPin != "" || txPin != "" {
+		if rxPin == "" || txPin == "" {
+			return ErrSerialPins
+		}
+		f.pinMutex.RLock()
+		var err error
+		if rx, err = f.resolvePin(rxPin); err == nil {
+			tx, err = f.resolvePin(txPin)
+		}
+		f.pinMutex.RUnlock()
+		if err != nil {
+			return err
+		}
+	} else if portID >= SoftwareSerial0 {
+		return ErrSerialPins
+	}
+
+	if err := b.SerialConfig(portID, baud, rx, tx); err != nil {
+		return err
+	}
+	return b.SerialRead(portID, 0)
+}
+
+// SerialWrite writes data to the serial port portID of the board, which must
+// have been opened with SerialConfig.
+func (f *Adaptor) SerialWrite(portID int, data []byte) error {
+	b, ok := f.board.(serialBoard)
+	if !ok {
+		return ErrNotSupported
+	}
+	if portID < 0 || portID > 0x0F {
+		return ErrSerialPort
+	}
+	return b.SerialWrite(portID, data)
+}
+
+// publishSerialRead publishes a reply of the board with the "SerialRead"
+// event.
+func (f *Adaptor) publishSerialRead(reply client.SerialReply) {
+	f.Publish(f.Event("SerialRead"), SerialData{Port: reply.Port, Data: reply.Data})
+}
@@ -0,0 +1,63 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorSerialConfig(t *testing.T) {
+	a := initTestAdaptor()
+	serial := a.board.(*mockFirmataBoard).serial
+
+	gobottest.Assert(t, a.SerialConfig(HardwareSerial1, 9600, "", ""), nil)
+	gobottest.Assert(t, a.SerialConfig(SoftwareSerial0, 57600, "10", "11"), nil)
+	gobottest.Assert(t, *serial, [][]int{
+		{int(client.SerialModeConfig), 1, 9600, -1, -1},
+		{int(client.SerialModeRead), 1, 0},
+		{int(client.SerialModeConfig), 8, 57600, 10, 11},
+		{int(client.SerialModeRead), 8, 0},
+	})
+
+	gobottest.Assert(t, a.SerialConfig(16, 9600, "", ""), ErrSerialPort)
+	gobottest.Assert(t, a.SerialConfig(HardwareSerial1, 0, "", ""), ErrSerialBaud)
+	gobottest.Assert(t, a.SerialConfig(HardwareSerial1, 1<<21, "", ""), ErrSerialBaud)
+	gobottest.Assert(t, a.SerialConfig(SoftwareSerial0, 9600, "", ""), ErrSerialPins)
+	gobottest.Assert(t, a.SerialConfig(SoftwareSerial0, 9600, "10", ""), ErrSerialPins)
+	gobottest.Refute(t, a.SerialConfig(SoftwareSerial0, 9600, "10", "a"), nil)
+	gobottest.Assert(t, len(*serial), 4)
+}
+
+func TestAdaptorSerialWrite(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.SerialWrite(HardwareSerial1, []byte{'$', 0xFF}), nil)
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).serial, [][]int{
+		{int(client.SerialModeWrite), 1, '$', 0xFF},
+	})
+	gobottest.Assert(t, a.SerialWrite(-1, []byte{1}), ErrSerialPort)
+}
+
+func TestAdaptorSerialRead(t *testing.T) {
+	a := initTestAdaptor()
+	sem := make(chan SerialData, 1)
+	a.On(a.Event("SerialRead"), func(data interface{}) {
+		sem <- data.(SerialData)
+	})
+
+	a.board.Publish(a.board.Event("SerialReply"), client.SerialReply{Port: 8, Data: []byte("$GP")})
+	select {
+	case data := <-sem:
+		gobottest.Assert(t, data, SerialData{Port: 8, Data: []byte("$GP")})
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("SerialRead was not published")
+	}
+}
+
+func TestAdaptorSerialNotSupported(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{})
+	a.board = struct{ FirmataBoard }{newMockFirmataBoard()}
+	gobottest.Assert(t, a.SerialConfig(HardwareSerial1, 9600, "", ""), ErrNotSupported)
+	gobottest.Assert(t, a.SerialWrite(HardwareSerial1, []byte{1}), ErrNotSupported)
+}
@@ -0,0 +1,126 @@
+package firmata
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Default servo pulse widths in microseconds, as used by the Arduino Servo
+// library when ServoConfig has not been called.
+const (
+	DefaultServoMinPulse = 544
+	DefaultServoMaxPulse = 2400
+)
+
+// servoMinWritePulse is the smallest value the Servo library writes as a pulse
+// width; smaller values are taken as angles.
+const servoMinWritePulse = 544
+
+// Errors
+var (
+	ErrInvalidSweep    = errors.New("sweep needs min < max, a non zero step and a positive interval")
+	ErrSpeedOutOfRange = errors.New("servo speed must be between -100 and 100")
+)
+
+// ServoRotate drives a continuous rotation servo on pin at speed, from -100
+// for full reverse through 0 to stop up to 100 for full forward. The speed is
+// mapped onto the pulse range set with ServoConfig, or the Servo library
+// default of 544-2400 microseconds, and written as a pulse width. As the
+// firmware takes values below 544 as angles, pulses below 544 microseconds are
+// written as 544.
+func (f *Adaptor) ServoRotate(pin string, speed int) error {
+	if speed < -100 || speed > 100 {
+		return ErrSpeedOutOfRange
+	}
+
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.ensureMode(p, client.Servo); err != nil {
+		return err
+	}
+
+	min, max := f.servoRange(p)
+	pulse := min + (speed+100)*(max-min)/200
+	if pulse < servoMinWritePulse {
+		pulse = servoMinWritePulse
+	}
+	return f.board.AnalogWrite(p, pulse)
+}
+
+// servoRange returns the pulse range configured for pin.
+func (f *Adaptor) servoRange(pin int) (min int, max int) {
+	f.pinMutex.RLock()
+	defer f.pinMutex.RUnlock()
+
+	if r, ok := f.servoRanges[pin]; ok {
+		return r[0], r[1]
+	}
+	return DefaultServoMinPulse, DefaultServoMaxPulse
+}
+
+// ServoSweep sweeps the servo on pin back and forth between the min and max
+// angles, moving step degrees every interval, until the returned stop
+// function is called. The pin is switched to servo mode once, before the
+// sweep starts. A write error ends the sweep and is reported by LastError.
+func (f *Adaptor) ServoSweep(pin string, min, max byte, step byte, interval time.Duration) (stop func(), err error) {
+	if min >= max || step == 0 || interval <= 0 {
+		return nil, ErrInvalidSweep
+	}
+
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = f.ensureMode(p, client.Servo); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		angle, up := int(min), true
+		for {
+			if err := f.board.AnalogWrite(p, angle); err != nil {
+				f.setLastError(err)
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			if up {
+				angle += int(step)
+			} else {
+				angle -= int(step)
+			}
+			if angle >= int(max) {
+				angle, up = int(max), false
+			} else if angle <= int(min) {
+				angle, up = int(min), true
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+	return stop, nil
+}
@@ -0,0 +1,72 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorServoSweep(t *testing.T) {
+	a := initTestAdaptor()
+	writes := make(chan int, 100)
+	a.board.(*mockFirmataBoard).analogWrite = func(pin int, value int) {
+		writes <- value
+	}
+
+	stop, err := a.ServoSweep("3", 10, 30, 15, time.Millisecond)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, a.board.Pins()[3].Mode, client.Servo)
+
+	for _, expected := range []int{10, 25, 30, 15, 10, 25} {
+		select {
+		case v := <-writes:
+			gobottest.Assert(t, v, expected)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("servo was not written")
+		}
+	}
+	stop()
+	stop()
+}
+
+func TestAdaptorServoSweepInvalid(t *testing.T) {
+	a := initTestAdaptor()
+	_, err := a.ServoSweep("3", 30, 10, 1, time.Millisecond)
+	gobottest.Assert(t, err, ErrInvalidSweep)
+	_, err = a.ServoSweep("3", 10, 30, 0, time.Millisecond)
+	gobottest.Assert(t, err, ErrInvalidSweep)
+	_, err = a.ServoSweep("3", 10, 30, 1, 0)
+	gobottest.Assert(t, err, ErrInvalidSweep)
+	_, err = a.ServoSweep("a", 10, 30, 1, time.Millisecond)
+	gobottest.Refute(t, err, nil)
+}
+
+func TestAdaptorServoRotate(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.ServoRotate("3", 0), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Mode, client.Servo)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 1472)
+	gobottest.Assert(t, a.ServoRotate("3", -100), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 544)
+
+	gobottest.Assert(t, a.ServoConfig("3", 1000, 2000), nil)
+	gobottest.Assert(t, a.ServoRotate("3", 100), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 2000)
+	gobottest.Assert(t, a.ServoRotate("3", 50), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 1750)
+
+	// pulses below 544 would be taken as angles
+	gobottest.Assert(t, a.ServoConfig("3", 400, 2400), nil)
+	gobottest.Assert(t, a.ServoRotate("3", -100), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 544)
+	gobottest.Assert(t, a.ServoRotate("3", -86), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 544)
+	gobottest.Assert(t, a.ServoRotate("3", -85), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 550)
+
+	gobottest.Assert(t, a.ServoRotate("3", 101), ErrSpeedOutOfRange)
+	gobottest.Assert(t, a.ServoRotate("3", -101), ErrSpeedOutOfRange)
+	gobottest.Refute(t, a.ServoRotate("a", 0), nil)
+}
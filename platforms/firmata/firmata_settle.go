@@ -0,0 +1,45 @@
+package firmata
+
+import (
+	"io"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// DefaultSettleDelay is the default time the Adaptor waits after opening a
+// serial port before starting the handshake. Boards such as the Arduino Uno
+// reset when the port is opened, and their bootloader takes about a second to
+// start the firmware, which does not see queries sent before it runs.
+const DefaultSettleDelay = 1500 * time.Millisecond
+
+// openSerial opens the serial port at baud.
+var openSerial = func(port string, baud int) (io.ReadWriteCloser, error) {
+	return serial.OpenPort(&serial.Config{Name: port, Baud: baud})
+}
+
+// WithSettleDelay sets how long the Adaptor waits after opening a serial port
+// before sending the first handshake query, for boards which are not ready to
+// answer right after the port is opened. The wait is not part of the handshake
+// timeout. Connections passed to NewAdaptor or opened by TCPAdaptor and
+// UDPAdaptor are used without waiting. A zero delay starts the handshake at
+// once.
+func WithSettleDelay(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.Settle = d
+	}
+}
+
+// openSerialPort opens the serial port at the baud rate of the Adaptor and
+// waits for the board to settle.
+func (f *Adaptor) openSerialPort(port string) (io.ReadWriteCloser, error) {
+	sp, err := openSerial(port, f.baud)
+	if err != nil {
+		return nil, err
+	}
+	if f.timeouts.Settle > 0 {
+		f.logger.Debugf("firmata: waiting %v for %v to settle", f.timeouts.Settle, port)
+		<-time.After(f.timeouts.Settle)
+	}
+	return sp, nil
+}
@@ -0,0 +1,46 @@
+package firmata
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorSettleDelay(t *testing.T) {
+	defer func(open func(string, int) (io.ReadWriteCloser, error)) {
+		openSerial = open
+	}(openSerial)
+	opened := make(chan time.Time, 1)
+	openSerial = func(port string, baud int) (io.ReadWriteCloser, error) {
+		gobottest.Assert(t, port, "/dev/null")
+		gobottest.Assert(t, baud, DefaultBaudRate)
+		opened <- time.Now()
+		return &readWriteCloser{}, nil
+	}
+
+	a := NewAdaptor("/dev/null")
+	gobottest.Assert(t, a.timeouts.Settle, DefaultSettleDelay)
+
+	connected := time.Now()
+	a = NewAdaptor("/dev/null", WithSettleDelay(50*time.Millisecond))
+	board := newMockFirmataBoard()
+	board.connect = func() error {
+		connected = time.Now()
+		return nil
+	}
+	a.board = board
+	gobottest.Assert(t, a.Connect(), nil)
+	if settled := connected.Sub(<-opened); settled < 50*time.Millisecond {
+		t.Errorf("handshake started %v after opening the port", settled)
+	}
+
+	openSerial = func(string, int) (io.ReadWriteCloser, error) {
+		return nil, errors.New("open error")
+	}
+	a = NewAdaptor("/dev/null", WithSettleDelay(time.Hour))
+	a.board = newMockFirmataBoard()
+	gobottest.Assert(t, a.Connect(), errors.New("open error"))
+}
@@ -0,0 +1,91 @@
+package firmata
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// SafeShutdownTimeout is how long Finalize waits for the safe values set with
+// WithSafeShutdown to be written before closing the connection regardless.
+const SafeShutdownTimeout = 500 * time.Millisecond
+
+// Errors
+var (
+	ErrSafeShutdownTimeout = errors.New("timed out writing the safe shutdown values")
+)
+
+// WithSafeShutdown makes Finalize write a safe value to each of the pins,
+// named as in Pin, before the connection is closed, such as 0 to stop a motor
+// or 90 to center a servo. Each value is written with ServoWrite, PwmWrite or
+// DigitalWrite, following the mode the pin is configured in, and pins in a
+// mode which cannot be written, such as inputs, are skipped with a log. The
+// writes are given SafeShutdownTimeout to complete, so
+// that an unresponsive board cannot hang Finalize; on timeout the connection
+// is closed anyway and Finalize returns ErrSafeShutdownTimeout.
+func WithSafeShutdown(values map[string]byte) Option {
+	return func(f *Adaptor) {
+		if f.safeValues == nil {
+			f.safeValues = make(map[string]byte)
+		}
+		for pin, value := range values {
+			f.safeValues[pin] = value
+		}
+	}
+}
+
+// safeShutdown writes the values set with WithSafeShutdown, waiting at most
+// timeout. Every pin is tried, and the first error is returned.
+func (f *Adaptor) safeShutdown(timeout time.Duration) error {
+	if len(f.safeValues) == 0 || !f.board.Connected() {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var first error
+		for pin, value := range f.safeValues {
+			if err := f.writeSafeValue(pin, value); err != nil {
+				f.logger.Errorf("firmata: writing safe value to pin %v: %v", pin, err)
+				if first == nil {
+					first = err
+				}
+			}
+		}
+		done <- first
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		f.logger.Errorf("firmata: %v", ErrSafeShutdownTimeout)
+		return ErrSafeShutdownTimeout
+	}
+}
+
+// writeSafeValue writes value to the pin with the write of its mode. A pin in
+// no mode that can be written is skipped, as it is not driven by the board.
+func (f *Adaptor) writeSafeValue(pin string, value byte) error {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(pin)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	state, _ := f.pin(p)
+	number := strconv.Itoa(p)
+	switch state.Mode {
+	case client.Servo:
+		return f.ServoWrite(number, value)
+	case client.Pwm:
+		return f.PwmWrite(number, value)
+	case client.Output:
+		return f.DigitalWrite(number, value)
+	}
+	f.logger.Infof("firmata: pin %v is in mode %v, skipping its safe value", pin, state.Mode)
+	return nil
+}
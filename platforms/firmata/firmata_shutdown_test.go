@@ -0,0 +1,57 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorSafeShutdown(t *testing.T) {
+	a := initTestAdaptor()
+	WithSafeShutdown(map[string]byte{"3": 90, "5": 0, "6": 0})(a)
+	gobottest.Assert(t, a.ServoWrite("3", 10), nil)
+	gobottest.Assert(t, a.DigitalWrite("5", 1), nil)
+	gobottest.Assert(t, a.PwmWrite("6", 200), nil)
+
+	gobottest.Assert(t, a.Finalize(), nil)
+	gobottest.Assert(t, a.board.Pins()[3].Value, 90)
+	gobottest.Assert(t, a.board.Pins()[5].Value, 0)
+	gobottest.Assert(t, a.board.Pins()[6].Mode, client.Pwm)
+	gobottest.Assert(t, a.board.Pins()[6].Value, 0)
+}
+
+func TestAdaptorSafeShutdownSkipsUnwritablePins(t *testing.T) {
+	l := &testLogger{}
+	a := initTestAdaptor()
+	WithLogger(l)(a)
+	WithSafeShutdown(map[string]byte{"7": 1})(a)
+	a.board.Pins()[7].Mode = client.Input
+
+	gobottest.Assert(t, a.Finalize(), nil)
+	gobottest.Assert(t, a.board.Pins()[7].Mode, client.Input)
+	gobottest.Assert(t, a.board.Pins()[7].Value, 0)
+	gobottest.Assert(t, l.messages[0], "info: firmata: pin 7 is in mode 0, skipping its safe value")
+}
+
+func TestAdaptorSafeShutdownInvalidPin(t *testing.T) {
+	a := initTestAdaptor()
+	WithSafeShutdown(map[string]byte{"x": 0})(a)
+	gobottest.Assert(t, a.Finalize(), ErrInvalidPin)
+}
+
+func TestAdaptorSafeShutdownTimeout(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.PwmWrite("3", 255), nil)
+	WithSafeShutdown(map[string]byte{"3": 0})(a)
+	block := make(chan struct{})
+	defer close(block)
+	a.board.(*mockFirmataBoard).analogWrite = func(int, int) {
+		<-block
+	}
+
+	start := time.Now()
+	gobottest.Assert(t, a.safeShutdown(10*time.Millisecond), ErrSafeShutdownTimeout)
+	gobottest.Assert(t, time.Since(start) < time.Second, true)
+}
@@ -0,0 +1,167 @@
+package firmata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// subscriptionBuffer is the number of samples buffered for each subscriber.
+// Samples arriving while the buffer is full are dropped.
+const subscriptionBuffer = 16
+
+// Errors
+var (
+	ErrInvalidWindow = errors.New("filter window must be greater than zero")
+)
+
+// SubscribeAnalogSmoothed enables reporting for the analog pin and returns a
+// channel of its readings averaged over the last window samples. Until window
+// samples have been received the average is taken over those available. The
+// returned function ends the subscription and closes the channel.
+func (f *Adaptor) SubscribeAnalogSmoothed(pin string, window int) (<-chan int, func(), error) {
+	if window < 1 {
+		return nil, nil, ErrInvalidWindow
+	}
+	return f.subscribeAnalog(pin, newMovingAverage(window))
+}
+
+// SubscribeAnalogMedian enables reporting for the analog pin and returns a
+// channel of the median of its last window readings, which rejects occasional
+// outliers better than an average does. The returned function ends the
+// subscription and closes the channel.
+func (f *Adaptor) SubscribeAnalogMedian(pin string, window int) (<-chan int, func(), error) {
+	if window < 1 {
+		return nil, nil, ErrInvalidWindow
+	}
+	return f.subscribeAnalog(pin, newMedian(window))
+}
+
+// subscribeAnalog enables reporting for the analog pin, named as in
+// AnalogRead, and returns a channel on which each reading is delivered after
+// being passed through filter, and a function which unsubscribes from the
+// board, closes the channel and releases the reporting. Reporting is held as
+// a watch holds it, so it stays on while the subscription is open.
+func (f *Adaptor) subscribeAnalog(pin string, filter func(int) int) (<-chan int, func(), error) {
+	p, channel, err := f.analogPin(pin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = f.ensureMode(p, client.Analog); err != nil {
+		return nil, nil, err
+	}
+
+	samples, stop := f.subscribeSamples(f.boardEvent(fmt.Sprintf("AnalogRead%v", channel)), filter)
+	release, err := f.acquireReport(analogReport, channel)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	cancel := func() {
+		stop()
+		release()
+	}
+	return samples, cancel, nil
+}
+
+// subscribeSamples returns a channel on which the value of each name event of
+// the board is delivered after being passed through filter, and a function
+// which unsubscribes from the board and closes the channel.
+func (f *Adaptor) subscribeSamples(name string, filter func(int) int) (<-chan int, func()) {
+	events := f.board.Subscribe()
+	samples := make(chan int, subscriptionBuffer)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case evt := <-events:
+				value, ok := evt.Data.(int)
+				if !ok || evt.Name != name {
+					continue
+				}
+				select {
+				case samples <- filter(value):
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			unsubscribe(f.board, events)
+			close(samples)
+		})
+	}
+	return samples, cancel
+}
+
+// newMovingAverage returns a filter which averages the last window values.
+func newMovingAverage(window int) func(int) int {
+	values := make([]int, 0, window)
+	next := 0
+	sum := 0
+
+	return func(value int) int {
+		if len(values) < window {
+			values = append(values, value)
+		} else {
+			sum -= values[next]
+			values[next] = value
+			next = (next + 1) % window
+		}
+		sum += value
+		return sum / len(values)
+	}
+}
+
+// newMedian returns a filter which takes the median of the last window
+// values. For an even number of values the lower of the two middle values is
+// used so the result is always an actual reading.
+func newMedian(window int) func(int) int {
+	values := make([]int, 0, window)
+	sorted := make([]int, 0, window)
+	next := 0
+
+	return func(value int) int {
+		if len(values) < window {
+			values = append(values, value)
+		} else {
+			values[next] = value
+			next = (next + 1) % window
+		}
+		sorted = append(sorted[:0], values...)
+		sort.Ints(sorted)
+		return sorted[(len(sorted)-1)/2]
+	}
+}
+
+// unsubscribe removes events from eventer. The eventer holds its lock while
+// delivering to subscribers, so events is drained until the removal is done.
+func unsubscribe(eventer gobot.Eventer, events chan *gobot.Event) {
+	done := make(chan struct{})
+	go func() {
+		eventer.Unsubscribe(events)
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-events:
+		case <-done:
+			return
+		}
+	}
+}
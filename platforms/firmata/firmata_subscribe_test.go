@@ -0,0 +1,122 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestMovingAverage(t *testing.T) {
+	filter := newMovingAverage(3)
+	gobottest.Assert(t, filter(3), 3)
+	gobottest.Assert(t, filter(6), 4)
+	gobottest.Assert(t, filter(9), 6)
+	gobottest.Assert(t, filter(12), 9)
+}
+
+func TestMedian(t *testing.T) {
+	filter := newMedian(3)
+	gobottest.Assert(t, filter(5), 5)
+	gobottest.Assert(t, filter(900), 5)
+	gobottest.Assert(t, filter(6), 6)
+	gobottest.Assert(t, filter(7), 7)
+	gobottest.Assert(t, filter(8), 7)
+}
+
+func TestAdaptorSubscribeAnalogMedian(t *testing.T) {
+	a := initTestAdaptor()
+	_, _, err := a.SubscribeAnalogMedian("1", 0)
+	gobottest.Assert(t, err, ErrInvalidWindow)
+
+	samples, cancel, err := a.SubscribeAnalogMedian("1", 3)
+	gobottest.Assert(t, err, nil)
+
+	a.board.Publish("AnalogRead1", 10)
+	select {
+	case value := <-samples:
+		gobottest.Assert(t, value, 10)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("sample was not delivered")
+	}
+
+	cancel()
+	cancel()
+	_, ok := <-samples
+	gobottest.Assert(t, ok, false)
+}
+
+func TestAdaptorSubscribeAnalogRawPins(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithRawAnalogPins(true), WithAnalogSettle(time.Millisecond))
+	a.board = newMockFirmataBoard()
+	a.Connect()
+	a.board.Pins()[15].AnalogChannel = 1
+
+	samples, cancel, err := a.SubscribeAnalogMedian("15", 1)
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("AnalogRead15", 900)
+	a.board.Publish("AnalogRead1", 10)
+	select {
+	case value := <-samples:
+		gobottest.Assert(t, value, 10)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("sample was not delivered")
+	}
+}
+
+func TestAdaptorSubscribeAnalogSmoothed(t *testing.T) {
+	a := initTestAdaptor()
+	_, _, err := a.SubscribeAnalogSmoothed("1", 0)
+	gobottest.Assert(t, err, ErrInvalidWindow)
+
+	samples, cancel, err := a.SubscribeAnalogSmoothed("1", 2)
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("AnalogRead1", 10)
+	a.board.Publish("AnalogRead2", 100)
+	a.board.Publish("AnalogRead1", 20)
+
+	for _, expected := range []int{10, 15} {
+		select {
+		case value := <-samples:
+			gobottest.Assert(t, value, expected)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("sample was not delivered")
+		}
+	}
+}
+
+func TestAdaptorSubscribeAnalogSmoothedAtoiError(t *testing.T) {
+	a := initTestAdaptor()
+	_, _, err := a.SubscribeAnalogSmoothed("a", 2)
+	gobottest.Refute(t, err, nil)
+}
+
+func TestAdaptorSubscribeAnalogHoldsReporting(t *testing.T) {
+	a := initTestAdaptor()
+	WithReadLinger(30 * time.Millisecond)(a)
+	board := a.board.(*mockFirmataBoard)
+	a.board.Pins()[16].AnalogChannel = 2
+	*board.reports = nil
+	reports := func() [][3]int {
+		board.mutex.Lock()
+		defer board.mutex.Unlock()
+		return append([][3]int(nil), *board.reports...)
+	}
+
+	_, cancel, err := a.SubscribeAnalogSmoothed("2", 2)
+	gobottest.Assert(t, err, nil)
+	<-time.After(80 * time.Millisecond)
+	gobottest.Assert(t, a.IsReporting("16"), true)
+	gobottest.Assert(t, reports(), [][3]int{{int(client.ReportAnalog), 2, 1}})
+
+	cancel()
+	gobottest.Assert(t, a.IsReporting("16"), false)
+	gobottest.Assert(t, reports(), [][3]int{
+		{int(client.ReportAnalog), 2, 1}, {int(client.ReportAnalog), 2, 0},
+	})
+}
@@ -0,0 +1,91 @@
+package firmata
+
+// rawWriter is implemented by boards which can write bytes to the connection
+// without interleaving them with their own writes, such as client.Client.
+type rawWriter interface {
+	WriteRaw([]byte) (int, error)
+}
+
+// SysexFeature is a self-contained Firmata feature module which owns a sysex
+// command byte. Once registered with RegisterSysexFeature, every sysex message
+// the board sends with that command is passed to Handle. A feature sends its
+// own messages using the Adaptor's WriteSysex.
+type SysexFeature interface {
+	// Command returns the sysex command byte handled by the feature.
+	Command() byte
+	// Handle is called with the payload of each sysex message received for
+	// the feature's command, excluding the command byte itself.
+	Handle(data []byte)
+}
+
+// RegisterSysexFeature registers feature as the handler for sysex messages
+// with its command byte. Returns ErrSysexFeatureRegistered if the command is
+// already handled by another feature.
+func (f *Adaptor) RegisterSysexFeature(feature SysexFeature) error {
+	f.featureMutex.Lock()
+	defer f.featureMutex.Unlock()
+
+	if _, ok := f.features[feature.Command()]; ok {
+		return ErrSysexFeatureRegistered
+	}
+	f.features[feature.Command()] = feature
+	return nil
+}
+
+// UnregisterSysexFeature removes the feature registered for command, if any.
+func (f *Adaptor) UnregisterSysexFeature(command byte) {
+	f.featureMutex.Lock()
+	defer f.featureMutex.Unlock()
+	delete(f.features, command)
+}
+
+// WriteSysex writes a sysex message with data as its command and payload to
+// the board.
+func (f *Adaptor) WriteSysex(data []byte) error {
+	return f.board.WriteSysex(data)
+}
+
+// WriteRaw writes b to the connection exactly as given, bypassing the framing
+// of the client, for experimenting with the protocol or custom firmware. It is
+// unsafe: bytes which do not form complete messages leave the firmware, or the
+// parser of the replies, out of step with the messages which follow, which may
+// then be misread until the board is reset. Returns ErrNotConnected before
+// Connect.
+func (f *Adaptor) WriteRaw(b []byte) (int, error) {
+	if f.conn == nil {
+		return 0, ErrNotConnected
+	}
+	if w, ok := f.board.(rawWriter); ok {
+		return w.WriteRaw(b)
+	}
+	return f.conn.Write(b)
+}
+
+// OnRawSysex calls handler with the command byte and payload of every sysex
+// message received from the board, whether or not it is understood, until the
+// returned function is called. It is called in addition to the handlers of
+// decoded messages, such as OnI2cReply or a SysexFeature, and runs as in
+// OnEvent. The payload excludes the StartSysex, command and EndSysex bytes.
+func (f *Adaptor) OnRawSysex(handler func(cmd byte, data []byte)) (cancel func()) {
+	return f.OnBoardEvent("RawSysex", func(data interface{}) {
+		if frame, ok := data.([]byte); ok && len(frame) >= 3 {
+			handler(frame[1], frame[2:len(frame)-1])
+		}
+	})
+}
+
+// dispatchSysex passes a complete sysex frame, including the StartSysex and
+// EndSysex bytes, to the feature registered for its command.
+func (f *Adaptor) dispatchSysex(frame []byte) {
+	if len(frame) < 3 {
+		return
+	}
+
+	f.featureMutex.Lock()
+	feature, ok := f.features[frame[1]]
+	f.featureMutex.Unlock()
+
+	if ok {
+		feature.Handle(frame[2 : len(frame)-1])
+	}
+}
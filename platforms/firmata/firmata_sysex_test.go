@@ -0,0 +1,74 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+type testSysexFeature struct {
+	data chan []byte
+}
+
+func (testSysexFeature) Command() byte { return 0x51 }
+
+func (s testSysexFeature) Handle(data []byte) { s.data <- data }
+
+func TestAdaptorRegisterSysexFeature(t *testing.T) {
+	a := initTestAdaptor()
+	feature := testSysexFeature{data: make(chan []byte, 1)}
+	gobottest.Assert(t, a.RegisterSysexFeature(feature), nil)
+	gobottest.Assert(t, a.RegisterSysexFeature(feature), ErrSysexFeatureRegistered)
+
+	a.board.Publish(a.board.Event("SysexResponse"), []byte{0xF0, 0x51, 1, 2, 0xF7})
+	select {
+	case data := <-feature.data:
+		gobottest.Assert(t, data, []byte{1, 2})
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("sysex feature was not called")
+	}
+
+	a.UnregisterSysexFeature(0x51)
+	gobottest.Assert(t, a.RegisterSysexFeature(feature), nil)
+}
+
+func TestAdaptorWriteSysex(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.WriteSysex([]byte{0x51, 1}), nil)
+}
+
+func TestAdaptorWriteRaw(t *testing.T) {
+	a := NewAdaptor("/dev/null")
+	_, err := a.WriteRaw([]byte{0xF9})
+	gobottest.Assert(t, err, ErrNotConnected)
+
+	a = initTestAdaptor()
+	testWriteData.Reset()
+	n, err := a.WriteRaw([]byte{0xF0, 0x51, 0x01})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 3)
+	gobottest.Assert(t, testWriteData.Bytes(), []byte{0xF0, 0x51, 0x01})
+}
+
+func TestAdaptorOnRawSysex(t *testing.T) {
+	a := initTestAdaptor()
+	type frame struct {
+		cmd  byte
+		data []byte
+	}
+	frames := make(chan frame, 1)
+	cancel := a.OnRawSysex(func(cmd byte, data []byte) {
+		frames <- frame{cmd, data}
+	})
+	defer cancel()
+
+	a.board.Publish(a.board.Event("RawSysex"), []byte{0xF0, 0x6A, 1, 2, 0xF7})
+	select {
+	case got := <-frames:
+		gobottest.Assert(t, got.cmd, byte(0x6A))
+		gobottest.Assert(t, got.data, []byte{1, 2})
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("raw sysex handler was not called")
+	}
+}
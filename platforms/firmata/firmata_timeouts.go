@@ -0,0 +1,63 @@
+package firmata
+
+import "time"
+
+// Timeouts holds the times the Adaptor waits for the board and the link to it.
+type Timeouts struct {
+	// Handshake is how long Connect waits for the board to answer the
+	// handshake queries, see WithHandshakeTimeout.
+	Handshake time.Duration
+	// Dial is how long TCPAdaptor waits to connect, see WithDialTimeout.
+	Dial time.Duration
+	// Settle is the wait after opening a serial port, see WithSettleDelay.
+	Settle time.Duration
+	// AnalogSettle is how long analog reads wait for the first sample, see
+	// WithAnalogSettle.
+	AnalogSettle time.Duration
+	// I2c is how long I2cPing and I2cCommandRead wait for a device, see
+	// WithI2cTimeout.
+	I2c time.Duration
+	// FreshRead is how long DigitalReadFresh waits for the pin, see
+	// WithFreshReadTimeout.
+	FreshRead time.Duration
+	// Flush is how long Disconnect waits for writes in progress, see
+	// WithFlushTimeout.
+	Flush time.Duration
+}
+
+// DefaultTimeouts are the timeouts of a new Adaptor.
+var DefaultTimeouts = Timeouts{
+	Handshake:    DefaultHandshakeTimeout,
+	Dial:         DefaultDialTimeout,
+	Settle:       DefaultSettleDelay,
+	AnalogSettle: DefaultAnalogSettle,
+	I2c:          DefaultI2cTimeout,
+	FreshRead:    DefaultFreshReadTimeout,
+	Flush:        DefaultFlushTimeout,
+}
+
+// WithTimeouts sets the timeouts of the Adaptor in one place, such as to
+// lengthen them all for a slow link. Fields left zero keep their value, the
+// default or the one set by an earlier option, so a zero timeout which has a
+// meaning of its own is set with the option for that timeout instead.
+func WithTimeouts(t Timeouts) Option {
+	return func(f *Adaptor) {
+		set := func(d *time.Duration, v time.Duration) {
+			if v != 0 {
+				*d = v
+			}
+		}
+		set(&f.timeouts.Handshake, t.Handshake)
+		set(&f.timeouts.Dial, t.Dial)
+		set(&f.timeouts.Settle, t.Settle)
+		set(&f.timeouts.AnalogSettle, t.AnalogSettle)
+		set(&f.timeouts.I2c, t.I2c)
+		set(&f.timeouts.FreshRead, t.FreshRead)
+		set(&f.timeouts.Flush, t.Flush)
+	}
+}
+
+// Timeouts returns the timeouts of the Adaptor.
+func (f *Adaptor) Timeouts() Timeouts {
+	return f.timeouts
+}
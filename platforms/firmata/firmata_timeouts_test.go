@@ -0,0 +1,30 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorWithTimeouts(t *testing.T) {
+	a := NewAdaptor("/dev/null")
+	gobottest.Assert(t, a.Timeouts(), DefaultTimeouts)
+
+	a = NewAdaptor("/dev/null",
+		WithI2cTimeout(3*time.Second),
+		WithTimeouts(Timeouts{Handshake: 20 * time.Second, AnalogSettle: time.Second}),
+		WithFreshReadTimeout(time.Second),
+	)
+	timeouts := DefaultTimeouts
+	timeouts.Handshake = 20 * time.Second
+	timeouts.AnalogSettle = time.Second
+	timeouts.I2c = 3 * time.Second
+	timeouts.FreshRead = time.Second
+	gobottest.Assert(t, a.Timeouts(), timeouts)
+
+	// a zero timeout is set with the option for it
+	a = NewAdaptor("/dev/null", WithTimeouts(Timeouts{Settle: time.Second}), WithSettleDelay(0))
+	gobottest.Assert(t, a.Timeouts().Settle, time.Duration(0))
+	gobottest.Assert(t, a.Timeouts().Handshake, DefaultHandshakeTimeout)
+}
@@ -0,0 +1,130 @@
+package firmata
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Tone sysex commands, as implemented by ToneFirmata in ConfigurableFirmata.
+const (
+	ToneCommand byte = 0x5F
+	toneTone    byte = 0x00
+	toneNoTone  byte = 0x01
+)
+
+// MelodyGap is the silence PlayMelody leaves between two notes, so that
+// repeated notes are heard separately.
+const MelodyGap = 20 * time.Millisecond
+
+// toneMax is the largest frequency or duration the tone message carries, as
+// both are sent in two 7-bit bytes.
+const toneMax = 0x3FFF
+
+// Errors
+var (
+	ErrToneRange = errors.New("tone frequency must be 1-16383 Hz and duration below 16384ms")
+)
+
+// Note is a note played by PlayMelody. A Frequency of zero is a rest.
+type Note struct {
+	Frequency int
+	Duration  time.Duration
+}
+
+// Tone plays a square wave of frequency Hz on pin for duration, or until
+// NoTone is called if duration is zero. The pin is switched to tone mode.
+func (f *Adaptor) Tone(pin string, frequency int, duration time.Duration) error {
+	ms := int(duration / time.Millisecond)
+	if frequency < 1 || frequency > toneMax || ms < 0 || ms > toneMax {
+		return ErrToneRange
+	}
+
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+	if _, err = f.ensureMode(p, client.Tone); err != nil {
+		return err
+	}
+
+	return f.board.WriteSysex([]byte{ToneCommand, toneTone, byte(p),
+		byte(frequency & 0x7F), byte((frequency >> 7) & 0x7F),
+		byte(ms & 0x7F), byte((ms >> 7) & 0x7F)})
+}
+
+// NoTone stops the tone playing on pin.
+func (f *Adaptor) NoTone(pin string) error {
+	p, err := strconv.Atoi(pin)
+	if err != nil {
+		return err
+	}
+	return f.board.WriteSysex([]byte{ToneCommand, toneNoTone, byte(p)})
+}
+
+// PlayMelody plays notes on pin in the background, each followed by
+// MelodyGap of silence. The returned stop function silences the pin and
+// waits for playback to end; it may be called before or after the melody has
+// finished. The notes are checked before playback starts, and a write error
+// during playback ends it and is reported by LastError.
+func (f *Adaptor) PlayMelody(pin string, notes []Note) (stop func(), err error) {
+	for _, note := range notes {
+		ms := int(note.Duration / time.Millisecond)
+		if note.Frequency < 0 || note.Frequency > toneMax || ms < 0 || ms > toneMax {
+			return nil, ErrToneRange
+		}
+	}
+	if _, err = strconv.Atoi(pin); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer f.NoTone(pin)
+
+		for _, note := range notes {
+			if note.Frequency > 0 {
+				if err := f.Tone(pin, note.Frequency, 0); err != nil {
+					f.setLastError(err)
+					return
+				}
+			}
+			if !sleepUnlessDone(note.Duration, done) {
+				return
+			}
+			if err := f.NoTone(pin); err != nil {
+				f.setLastError(err)
+				return
+			}
+			if !sleepUnlessDone(MelodyGap, done) {
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+	return stop, nil
+}
+
+// sleepUnlessDone waits for d, and reports false if done was closed first.
+func sleepUnlessDone(d time.Duration, done chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
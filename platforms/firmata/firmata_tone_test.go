@@ -0,0 +1,81 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorTone(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.Tone("8", 440, 200*time.Millisecond), nil)
+	gobottest.Assert(t, a.board.Pins()[8].Mode, client.Tone)
+	gobottest.Assert(t, a.NoTone("8"), nil)
+
+	sysex := *a.board.(*mockFirmataBoard).sysex
+	gobottest.Assert(t, sysex[len(sysex)-2],
+		[]byte{ToneCommand, toneTone, 8, 0x38, 0x03, 0x48, 0x01})
+	gobottest.Assert(t, sysex[len(sysex)-1], []byte{ToneCommand, toneNoTone, 8})
+
+	gobottest.Assert(t, a.Tone("8", 0, 0), ErrToneRange)
+	gobottest.Assert(t, a.Tone("8", 16384, 0), ErrToneRange)
+	gobottest.Assert(t, a.Tone("8", 440, 20*time.Second), ErrToneRange)
+	gobottest.Refute(t, a.Tone("a", 440, 0), nil)
+	gobottest.Refute(t, a.NoTone("a"), nil)
+}
+
+func TestAdaptorPlayMelody(t *testing.T) {
+	a := initTestAdaptor()
+	before := len(*a.board.(*mockFirmataBoard).sysex)
+
+	stop, err := a.PlayMelody("8", []Note{
+		{Frequency: 440, Duration: time.Millisecond},
+		{Frequency: 0, Duration: time.Millisecond},
+		{Frequency: 880, Duration: time.Millisecond},
+	})
+	gobottest.Assert(t, err, nil)
+	time.Sleep(200 * time.Millisecond)
+	stop()
+	stop()
+
+	var tones []int
+	for _, data := range (*a.board.(*mockFirmataBoard).sysex)[before:] {
+		if data[0] == ToneCommand && data[1] == toneTone {
+			tones = append(tones, int(data[3])|int(data[4])<<7)
+		}
+	}
+	gobottest.Assert(t, tones, []int{440, 880})
+	gobottest.Assert(t, a.LastError(), nil)
+}
+
+func TestAdaptorPlayMelodyStop(t *testing.T) {
+	a := initTestAdaptor()
+	stop, err := a.PlayMelody("8", []Note{{Frequency: 440, Duration: 10 * time.Second}})
+	gobottest.Assert(t, err, nil)
+
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("melody was not stopped")
+	}
+
+	sysex := *a.board.(*mockFirmataBoard).sysex
+	gobottest.Assert(t, sysex[len(sysex)-1], []byte{ToneCommand, toneNoTone, 8})
+}
+
+func TestAdaptorPlayMelodyInvalid(t *testing.T) {
+	a := initTestAdaptor()
+	_, err := a.PlayMelody("8", []Note{{Frequency: -1, Duration: time.Millisecond}})
+	gobottest.Assert(t, err, ErrToneRange)
+	_, err = a.PlayMelody("8", []Note{{Frequency: 440, Duration: 20 * time.Second}})
+	gobottest.Assert(t, err, ErrToneRange)
+	_, err = a.PlayMelody("a", nil)
+	gobottest.Refute(t, err, nil)
+}
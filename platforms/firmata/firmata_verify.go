@@ -0,0 +1,96 @@
+package firmata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModeMismatch describes a pin which Verify did not find in the expected mode.
+type ModeMismatch struct {
+	// Pin is the pin as named in the map passed to Verify.
+	Pin string
+	// Want is the expected mode.
+	Want int
+	// Got is the mode the board reported, or -1 if it did not report one.
+	Got int
+	// Err is the reason no mode was reported, such as ErrInvalidPin for a pin
+	// the board does not have, or nil if the board reported another mode.
+	Err error
+}
+
+func (m ModeMismatch) String() string {
+	if m.Err != nil {
+		return fmt.Sprintf("pin %v: %v", m.Pin, m.Err)
+	}
+	return fmt.Sprintf("pin %v: mode %v, want %v", m.Pin, m.Got, m.Want)
+}
+
+// VerifyError is returned by Verify for the pins which failed verification.
+type VerifyError struct {
+	Mismatches []ModeMismatch
+}
+
+func (e *VerifyError) Error() string {
+	lines := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		lines[i] = m.String()
+	}
+	return fmt.Sprintf("%v pins failed verification: %v", len(lines), strings.Join(lines, "; "))
+}
+
+// Verify checks that the board has each pin of pins, named as in Pin, and
+// that it is in the mode given for it, as a check before starting a robot.
+// The mode is asked from the board with a pin state query rather than taken
+// from the modes the Adaptor set, so that firmware which lost or refused a
+// mode change is caught. Verify changes no modes. Returns a *VerifyError
+// listing the pins, sorted by name, which are missing, in another mode or for
+// which the board did not answer, and ErrNotSupported for boards which cannot
+// query the pin state.
+func (f *Adaptor) Verify(pins map[string]int) error {
+	querier, ok := f.board.(pinStateQuerier)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	names := make([]string, 0, len(pins))
+	for name := range pins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mismatches := []ModeMismatch{}
+	for _, name := range names {
+		m := ModeMismatch{Pin: name, Want: pins[name], Got: -1}
+		m.Got, m.Err = f.queryMode(querier, name)
+		if m.Err != nil || m.Got != m.Want {
+			mismatches = append(mismatches, m)
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return &VerifyError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// queryMode returns the mode the board reports for the pin, named as in Pin.
+func (f *Adaptor) queryMode(querier pinStateQuerier, name string) (int, error) {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(name)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return -1, err
+	}
+
+	events := f.board.Subscribe()
+	defer unsubscribe(f.board, events)
+	if err = querier.PinStateQuery(p); err != nil {
+		return -1, err
+	}
+	state, ok := awaitPinState(events, f.boardEvent(fmt.Sprintf("PinState%v", p)))
+	if !ok {
+		return -1, ErrReadTimeout
+	}
+	return state.Mode, nil
+}
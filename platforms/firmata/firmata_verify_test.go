@@ -0,0 +1,40 @@
+package firmata
+
+import (
+	"testing"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorVerify(t *testing.T) {
+	a := initTestAdaptor()
+	board := a.board.(*mockFirmataBoard)
+	board.pins[2].Mode = client.Output
+	board.pins[3].Mode = client.Pwm
+	gobottest.Assert(t, a.SetPinAlias("led", "3"), nil)
+
+	gobottest.Assert(t, a.Verify(map[string]int{"2": client.Output, "led": client.Pwm}), nil)
+	gobottest.Assert(t, a.Verify(map[string]int{}), nil)
+
+	board.pinStateSilent = func(pin int) bool {
+		return pin == 4
+	}
+	err := a.Verify(map[string]int{
+		"2":   client.Input,
+		"4":   client.Output,
+		"200": client.Output,
+		"led": client.Pwm,
+	})
+	gobottest.Assert(t, err, &VerifyError{Mismatches: []ModeMismatch{
+		{Pin: "2", Want: client.Input, Got: client.Output},
+		{Pin: "200", Want: client.Output, Got: -1, Err: ErrInvalidPin},
+		{Pin: "4", Want: client.Output, Got: -1, Err: ErrReadTimeout},
+	}})
+	gobottest.Assert(t, err.Error(), "3 pins failed verification: "+
+		"pin 2: mode 1, want 0; pin 200: invalid pin; "+
+		"pin 4: timed out waiting for the board to report the pin")
+
+	// verifying changes no modes
+	gobottest.Assert(t, len(*board.modes), 0)
+}
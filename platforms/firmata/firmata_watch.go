@@ -0,0 +1,160 @@
+package firmata
+
+import (
+	"fmt"
+	"sync"
+
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+// Watch calls handler with the value of the pin each time it changes. Pins in
+// analog mode are watched through analog reports; any other pin is switched
+// to input mode and watched through digital reports. The pin may be named as
+// in Pin. Calling the returned cancel function stops the handler, and disables
+// reporting once no other watcher needs it. The handler runs on its own
+// goroutine, so it may read pins or watch others itself, and changes arriving
+// while it runs are queued. The handler is not called after cancel returns,
+// so cancel must not be called from within the handler.
+// Watching a pin set up with SetDebounce reports its debounced value. Digital
+// values of a pin marked with SetInverted are inverted, as in DigitalRead.
+func (f *Adaptor) Watch(pin string, handler func(value int)) (cancel func(), err error) {
+	f.pinMutex.RLock()
+	p, err := f.resolvePin(pin)
+	f.pinMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	var kind reportKind
+	var index int
+
+	if state, _ := f.pin(p); state.Mode == client.Analog {
+		kind, index = analogReport, state.AnalogChannel
+		name = f.boardEvent(fmt.Sprintf("AnalogRead%v", index))
+	} else {
+		if _, err = f.ensureMode(p, client.Input); err != nil {
+			return nil, err
+		}
+		raw := handler
+		handler = func(value int) {
+			if f.isInverted(p) {
+				value = int(invertLevel(byte(value)))
+			}
+			raw(value)
+		}
+		if d := f.debouncer(p); d != nil {
+			return d.watch(handler), nil
+		}
+		kind, index = digitalReport, p/8
+		name = f.boardEvent(fmt.Sprintf("DigitalRead%v", p))
+	}
+
+	events := f.board.Subscribe()
+	release, err := f.acquireReport(kind, index)
+	if err != nil {
+		unsubscribe(f.board, events)
+		return nil, err
+	}
+
+	queue := newHandlerQueue(onInt(handler))
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		last, seen := 0, false
+		for {
+			select {
+			case evt := <-events:
+				value, ok := evt.Data.(int)
+				if evt.Name != name || !ok || (seen && value == last) {
+					continue
+				}
+				last, seen = value, true
+				queue.push(value)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+			unsubscribe(f.board, events)
+			queue.close()
+			release()
+		})
+	}
+	return cancel, nil
+}
+
+// handlerQueue calls a handler on its own goroutine with the data pushed by
+// the goroutine receiving events. An eventer holds its lock while delivering
+// an event, so a handler called by the receiving goroutine itself could not
+// subscribe to events without deadlocking.
+type handlerQueue struct {
+	pending []interface{}
+	ready   chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	mutex   sync.Mutex
+}
+
+func newHandlerQueue(handler func(interface{})) *handlerQueue {
+	q := &handlerQueue{
+		ready: make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go q.run(handler)
+	return q
+}
+
+// push queues data for the handler.
+func (q *handlerQueue) push(data interface{}) {
+	q.mutex.Lock()
+	q.pending = append(q.pending, data)
+	q.mutex.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *handlerQueue) run(handler func(interface{})) {
+	defer close(q.done)
+	for {
+		select {
+		case <-q.ready:
+		case <-q.stop:
+			return
+		}
+		for {
+			q.mutex.Lock()
+			if len(q.pending) == 0 {
+				q.mutex.Unlock()
+				break
+			}
+			data := q.pending[0]
+			q.pending = q.pending[1:]
+			q.mutex.Unlock()
+
+			select {
+			case <-q.stop:
+				return
+			default:
+			}
+			handler(data)
+		}
+	}
+}
+
+// close discards the queued data and waits for a running handler to return.
+func (q *handlerQueue) close() {
+	close(q.stop)
+	<-q.done
+}
@@ -0,0 +1,175 @@
+package firmata
+
+import (
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/platforms/firmata/client"
+)
+
+func TestAdaptorWatchDigital(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	values := make(chan int, 10)
+
+	cancel, err := a.Watch("10", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	cancel2, err := a.Watch("11", func(int) {})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, *reports, [][3]int{{int(client.ReportDigital), 1, 1}})
+
+	for _, v := range []int{1, 1, 0} {
+		a.board.Publish("DigitalRead10", v)
+	}
+	a.board.Publish("DigitalRead11", 1)
+
+	for _, expected := range []int{1, 0} {
+		select {
+		case v := <-values:
+			gobottest.Assert(t, v, expected)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("handler was not called")
+		}
+	}
+
+	cancel()
+	cancel()
+	gobottest.Assert(t, len(*reports), 1)
+	cancel2()
+	gobottest.Assert(t, (*reports)[1], [3]int{int(client.ReportDigital), 1, 0})
+}
+
+func TestAdaptorWatchAnalog(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.Pins()[15].Mode = client.Analog
+	a.board.Pins()[15].AnalogChannel = 1
+	values := make(chan int, 1)
+
+	cancel, err := a.Watch("15", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	a.board.Publish("AnalogRead1", 512)
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 512)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+	cancel()
+	gobottest.Assert(t, *a.board.(*mockFirmataBoard).reports, [][3]int{
+		{int(client.ReportAnalog), 1, 1},
+		{int(client.ReportAnalog), 1, 0},
+	})
+}
+
+func TestAdaptorWatchHandlerWatches(t *testing.T) {
+	a := initTestAdaptor()
+	values := make(chan int, 2)
+
+	cancel, err := a.Watch("10", func(value int) {
+		other, _ := a.Watch("11", func(int) {})
+		other()
+		values <- value
+	})
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("DigitalRead10", 1)
+	a.board.Publish("DigitalRead10", 0)
+	for _, expected := range []int{1, 0} {
+		select {
+		case v := <-values:
+			gobottest.Assert(t, v, expected)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("handler was not called")
+		}
+	}
+}
+
+func TestAdaptorWatchInvalidPin(t *testing.T) {
+	a := initTestAdaptor()
+	_, err := a.Watch("x", func(int) {})
+	gobottest.Assert(t, err, ErrInvalidPin)
+}
+
+func TestAdaptorWatchInverted(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.SetInverted("10", true), nil)
+	values := make(chan int, 10)
+	cancel, err := a.Watch("10", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("DigitalRead10", 1)
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 0)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+}
+
+func TestAdaptorWatchInvertedDebounced(t *testing.T) {
+	a := initTestAdaptor()
+	gobottest.Assert(t, a.SetInverted("10", true), nil)
+	gobottest.Assert(t, a.SetDebounce("10", 10*time.Millisecond), nil)
+	values := make(chan int, 10)
+	cancel, err := a.Watch("10", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+
+	a.board.Publish("DigitalRead10", 1)
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 0)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("handler was not called")
+	}
+}
+
+func TestAdaptorWatchReconnect(t *testing.T) {
+	a := initTestAdaptor()
+	reports := a.board.(*mockFirmataBoard).reports
+	restored := make(chan interface{}, 1)
+	a.On(a.Event("ReportingRestored"), func(data interface{}) {
+		restored <- data
+	})
+	values := make(chan int, 10)
+
+	cancel, err := a.Watch("10", func(value int) { values <- value })
+	gobottest.Assert(t, err, nil)
+	defer cancel()
+	a.board.Pins()[16].Mode = client.Analog
+	cancel2, err := a.Watch("16", func(int) {})
+	gobottest.Assert(t, err, nil)
+	defer cancel2()
+	*reports = (*reports)[:0]
+
+	gobottest.Assert(t, a.Disconnect(), nil)
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, len(*reports), 2)
+	for _, expected := range [][3]int{
+		{int(client.ReportDigital), 1, 1},
+		{int(client.ReportAnalog), 0, 1},
+	} {
+		found := false
+		for _, report := range *reports {
+			found = found || report == expected
+		}
+		gobottest.Assert(t, found, true)
+	}
+	select {
+	case data := <-restored:
+		gobottest.Assert(t, data, 2)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("ReportingRestored was not published")
+	}
+
+	a.board.Publish("DigitalRead10", 1)
+	select {
+	case v := <-values:
+		gobottest.Assert(t, v, 1)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("handler was not called after reconnect")
+	}
+}
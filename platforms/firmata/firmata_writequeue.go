@@ -0,0 +1,127 @@
+package firmata
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultFlushTimeout is the default time Disconnect waits for writes in
+// progress to complete.
+const DefaultFlushTimeout = 500 * time.Millisecond
+
+// Errors
+var (
+	ErrFlushTimeout = errors.New("timed out flushing pending writes")
+)
+
+// WithWriteBuffer makes DigitalWrite, PwmWrite and ServoWrite queue up to size
+// writes while the board is disconnected instead of failing. The queued writes
+// are sent in order once Connect succeeds again. When the queue is full the
+// oldest write is dropped. A size of zero, the default, disables queueing.
+func WithWriteBuffer(size int) Option {
+	return func(f *Adaptor) {
+		f.writeQueue.size = size
+	}
+}
+
+// WithFlushTimeout sets how long Disconnect waits for writes in progress to
+// reach the board before closing the connection.
+func WithFlushTimeout(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.Flush = d
+	}
+}
+
+type writeQueue struct {
+	size     int
+	writes   []func() error
+	inflight int
+	idle     chan struct{}
+	mutex    sync.Mutex
+}
+
+// queueWrite queues write if queueing is enabled and the board is not
+// connected. Returns whether write was queued.
+func (f *Adaptor) queueWrite(write func() error) bool {
+	q := &f.writeQueue
+	if q.size <= 0 || f.board.Connected() {
+		return false
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.writes) >= q.size {
+		q.writes = q.writes[1:]
+	}
+	q.writes = append(q.writes, write)
+	return true
+}
+
+// flushWrites sends the queued writes to the board. On the first error the
+// failed write and those after it are put back at the front of the queue, so
+// that they are sent in order by the next flush.
+func (f *Adaptor) flushWrites() error {
+	q := &f.writeQueue
+	q.mutex.Lock()
+	writes := q.writes
+	q.writes = nil
+	q.mutex.Unlock()
+
+	for i, write := range writes {
+		if err := write(); err != nil {
+			q.mutex.Lock()
+			q.writes = append(writes[i:len(writes):len(writes)], q.writes...)
+			if len(q.writes) > q.size {
+				q.writes = q.writes[len(q.writes)-q.size:]
+			}
+			q.mutex.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// beginWrite marks a write to the board as in progress. The returned function
+// must be called once the write has returned.
+func (f *Adaptor) beginWrite() func() {
+	q := &f.writeQueue
+	q.mutex.Lock()
+	q.inflight++
+	q.mutex.Unlock()
+
+	return func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		q.inflight--
+		if q.inflight == 0 && q.idle != nil {
+			close(q.idle)
+			q.idle = nil
+		}
+	}
+}
+
+// drainWrites waits for the writes in progress to return, at most the flush
+// timeout. Returns ErrFlushTimeout if they did not complete in time. Writes
+// queued while disconnected are not sent, as there is no board to send them
+// to.
+func (f *Adaptor) drainWrites() error {
+	q := &f.writeQueue
+	q.mutex.Lock()
+	if q.inflight == 0 {
+		q.mutex.Unlock()
+		return nil
+	}
+	if q.idle == nil {
+		q.idle = make(chan struct{})
+	}
+	idle := q.idle
+	q.mutex.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-time.After(f.timeouts.Flush):
+		return ErrFlushTimeout
+	}
+}
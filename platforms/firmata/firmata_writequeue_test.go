@@ -0,0 +1,81 @@
+package firmata
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestAdaptorWriteBuffer(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithWriteBuffer(2))
+	board := newMockFirmataBoard()
+	a.board = board
+	board.disconnected = true
+
+	gobottest.Assert(t, a.DigitalWrite("2", 1), nil)
+	gobottest.Assert(t, a.DigitalWrite("3", 1), nil)
+	gobottest.Assert(t, a.DigitalWrite("4", 1), nil)
+	gobottest.Assert(t, a.PwmWrite("5", 10), nil)
+	gobottest.Assert(t, board.pins[4].Value, 0)
+
+	board.disconnected = false
+	gobottest.Assert(t, a.Connect(), nil)
+	// the oldest writes were dropped when the queue overflowed
+	gobottest.Assert(t, board.pins[2].Value, 0)
+	gobottest.Assert(t, board.pins[3].Value, 0)
+	gobottest.Assert(t, board.pins[4].Value, 1)
+	gobottest.Assert(t, len(a.writeQueue.writes), 0)
+}
+
+func TestAdaptorWriteBufferFlushError(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithWriteBuffer(3))
+	board := newMockFirmataBoard()
+	a.board = board
+	board.disconnected = true
+
+	var sent []int
+	gobottest.Assert(t, a.queueWrite(func() error { sent = append(sent, 1); return nil }), true)
+	gobottest.Assert(t, a.queueWrite(func() error { return errors.New("write error") }), true)
+	gobottest.Assert(t, a.queueWrite(func() error { sent = append(sent, 3); return nil }), true)
+
+	board.disconnected = false
+	gobottest.Assert(t, a.Connect(), nil)
+	gobottest.Assert(t, sent, []int{1})
+	// the failed write and the ones after it stay queued
+	gobottest.Assert(t, len(a.writeQueue.writes), 2)
+}
+
+func TestAdaptorWriteBufferDisabled(t *testing.T) {
+	a := initTestAdaptor()
+	a.board.(*mockFirmataBoard).disconnected = true
+	gobottest.Assert(t, a.queueWrite(func() error { return nil }), false)
+}
+
+func TestAdaptorDisconnectWaitsForWrites(t *testing.T) {
+	a := initTestAdaptor()
+	done := a.beginWrite()
+	finished := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(finished)
+		done()
+	}()
+
+	gobottest.Assert(t, a.Disconnect(), nil)
+	select {
+	case <-finished:
+	default:
+		t.Fatalf("Disconnect returned before the write completed")
+	}
+}
+
+func TestAdaptorDisconnectFlushTimeout(t *testing.T) {
+	a := NewAdaptor(&readWriteCloser{}, WithFlushTimeout(time.Millisecond))
+	a.board = newMockFirmataBoard()
+	done := a.beginWrite()
+	defer done()
+
+	gobottest.Assert(t, a.Disconnect(), ErrFlushTimeout)
+}
@@ -1,6 +1,21 @@
 package firmata
 
-import "net"
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultDialTimeout is the default time Connect waits for the TCP connection
+// to the board to be established.
+const DefaultDialTimeout = 5 * time.Second
+
+// Errors
+var (
+	ErrDialTimeout = errors.New("timed out connecting to the board")
+)
 
 // TCPAdaptor represents a TCP based connection to a microcontroller running
 // WiFiFirmata
@@ -8,16 +23,58 @@ type TCPAdaptor struct {
 	*Adaptor
 }
 
-// NewTCPAdaptor opens and uses a TCP connection to a microcontroller running
-// WiFiFirmata
+// WithDialTimeout sets how long Connect waits for the TCP connection to the
+// board to be established, including the TLS handshake when encrypted, before
+// giving up with ErrDialTimeout. It is separate from the Firmata handshake
+// timeout set with WithHandshakeTimeout. A zero timeout waits as long as the
+// operating system does. It only applies to TCPAdaptor.
+func WithDialTimeout(d time.Duration) Option {
+	return func(f *Adaptor) {
+		f.timeouts.Dial = d
+	}
+}
+
+// NewTCPAdaptor returns an adaptor for a microcontroller running WiFiFirmata
+// at the given address. The address comes first, and may be followed by a
+// *tls.Config to encrypt the connection, as well as any of the options of
+// NewAdaptor. The connection is opened by Connect, which fails with
+// ErrDialTimeout if the board cannot be reached within the dial timeout.
+//
+// With a *tls.Config, Connect also fails if the TLS handshake does. The
+// certificate of the board is verified unless the config sets
+// InsecureSkipVerify, which is only meant for development boards with
+// self-signed certificates.
 func NewTCPAdaptor(args ...interface{}) *TCPAdaptor {
 	address := args[0].(string)
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		// TODO: handle error
+
+	var config *tls.Config
+	options := []interface{}{}
+	for _, arg := range args[1:] {
+		if c, ok := arg.(*tls.Config); ok {
+			config = c
+			continue
+		}
+		options = append(options, arg)
 	}
 
-	a := NewAdaptor(conn, address)
+	a := NewAdaptor(append(options, address)...)
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		dialer := &net.Dialer{Timeout: a.timeouts.Dial}
+		var conn io.ReadWriteCloser
+		var err error
+		if config != nil {
+			conn, err = tls.DialWithDialer(dialer, "tcp", port, config)
+		} else {
+			conn, err = dialer.Dial("tcp", port)
+		}
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return nil, ErrDialTimeout
+			}
+			return nil, err
+		}
+		return conn, nil
+	}
 	a.SetName("TCPFirmata")
 
 	return &TCPAdaptor{
@@ -1,7 +1,11 @@
 package firmata
 
 import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"gobot.io/x/gobot"
 	"gobot.io/x/gobot/gobottest"
@@ -18,3 +22,45 @@ func TestFirmataTCPAdaptor(t *testing.T) {
 	a := initTestTCPAdaptor()
 	gobottest.Assert(t, a.Name(), "TCPFirmata")
 }
+
+func TestFirmataTCPAdaptorTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	address := server.Listener.Addr().String()
+
+	a := NewTCPAdaptor(address, &tls.Config{})
+	gobottest.Assert(t, a.Name(), "TCPFirmata")
+	gobottest.Assert(t, a.Port(), address)
+	_, err := a.openCommPort(a.Port())
+	gobottest.Refute(t, err, nil)
+
+	a = NewTCPAdaptor(address, &tls.Config{InsecureSkipVerify: true}, WithHandshakeTimeout(0))
+	gobottest.Assert(t, a.timeouts.Handshake, time.Duration(0))
+	conn, err := a.openCommPort(a.Port())
+	gobottest.Assert(t, err, nil)
+	conn.Close()
+}
+
+func TestFirmataTCPAdaptorDialTimeout(t *testing.T) {
+	a := initTestTCPAdaptor()
+	gobottest.Assert(t, a.timeouts.Dial, DefaultDialTimeout)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	address := server.Listener.Addr().String()
+
+	a = NewTCPAdaptor(address, WithDialTimeout(time.Second))
+	gobottest.Assert(t, a.timeouts.Dial, time.Second)
+	gobottest.Assert(t, a.Port(), address)
+	conn, err := a.openCommPort(a.Port())
+	gobottest.Assert(t, err, nil)
+	conn.Close()
+
+	a = NewTCPAdaptor(address, WithDialTimeout(time.Nanosecond))
+	_, err = a.openCommPort(a.Port())
+	gobottest.Assert(t, err, ErrDialTimeout)
+
+	a = NewTCPAdaptor(address, &tls.Config{}, WithDialTimeout(time.Nanosecond))
+	_, err = a.openCommPort(a.Port())
+	gobottest.Assert(t, err, ErrDialTimeout)
+}
@@ -0,0 +1,260 @@
+package firmata
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// udpPacketSize is large enough for any datagram on a LAN.
+const udpPacketSize = 1500
+
+// Datagram kinds of the UDP transport.
+const (
+	udpData byte = 0x00
+	udpAck  byte = 0x01
+)
+
+const (
+	// udpHeaderSize is the size of the kind and sequence number which start
+	// every datagram.
+	udpHeaderSize = 3
+	// udpWindow is how far ahead of the next expected datagram one may be
+	// and still be held for reordering. Datagrams further away in either
+	// direction mean the peer restarted its numbering.
+	udpWindow = 64
+	// udpRetransmit is how long a datagram waits for its ack before being
+	// sent again.
+	udpRetransmit = 20 * time.Millisecond
+	// udpRetries is how many times a datagram is sent again before it is
+	// given up as lost.
+	udpRetries = 5
+	// udpGapTimeout is how long a missing datagram is waited for before the
+	// datagrams after it are delivered without it. It covers the retries of
+	// the sender.
+	udpGapTimeout = udpRetransmit * (udpRetries + 1)
+)
+
+// UDPAdaptor represents a UDP based connection to a microcontroller running
+// Firmata over a network. UDP avoids the head-of-line blocking of TCP, at the
+// cost of weaker delivery guarantees, so it is meant for reliable local
+// networks. See DialUDP for the datagram format the board must speak.
+type UDPAdaptor struct {
+	*Adaptor
+}
+
+// NewUDPAdaptor returns an adaptor for the microcontroller at the given
+// address, such as "192.168.0.10:3030". It accepts the same options as
+// NewAdaptor after the address. The socket is opened by Connect.
+func NewUDPAdaptor(address string, args ...interface{}) *UDPAdaptor {
+	a := NewAdaptor(append([]interface{}{address}, args...)...)
+	a.SetName("UDPFirmata")
+	a.openCommPort = func(port string) (io.ReadWriteCloser, error) {
+		return DialUDP(port)
+	}
+
+	return &UDPAdaptor{
+		Adaptor: a,
+	}
+}
+
+// udpDatagram is a data datagram waiting for its ack.
+type udpDatagram struct {
+	data  []byte
+	sent  time.Time
+	tries int
+}
+
+// udpConn presents a UDP socket as the byte stream the Firmata parser reads.
+type udpConn struct {
+	conn   net.Conn
+	packet []byte
+
+	mutex   sync.Mutex
+	sendSeq uint16
+	unacked map[uint16]*udpDatagram
+	done    chan struct{}
+	once    sync.Once
+
+	// the receiving side, including the handling of acks, is run by Read,
+	// which the client calls continuously
+	pending  []byte
+	recvSeq  uint16
+	held     map[uint16][]byte
+	gapSince time.Time
+}
+
+// DialUDP opens a UDP connection to address which can be passed to
+// NewAdaptor. Every datagram starts with a kind byte, 0x00 for data and 0x01
+// for an ack, followed by a 16-bit big-endian sequence number. Data datagrams
+// carry the Firmata bytes after the header, and are numbered from 0 in the
+// order they are sent. Each data datagram is answered with an ack carrying
+// its number, and is sent again every 20ms until acked, at most five times.
+// Received data is delivered to Read in sequence order with duplicates
+// dropped. A datagram still missing once the sender has stopped retrying is
+// given up, and the parser resynchronizes on the message after it. Each Write
+// is sent as one datagram, or several if it is longer than a datagram holds.
+func DialUDP(address string) (io.ReadWriteCloser, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &udpConn{
+		conn:    conn,
+		packet:  make([]byte, udpPacketSize),
+		unacked: make(map[uint16]*udpDatagram),
+		done:    make(chan struct{}),
+		held:    make(map[uint16][]byte),
+	}
+	go c.retransmit()
+	return c, nil
+}
+
+func (c *udpConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if data, ok := c.held[c.recvSeq]; ok {
+			delete(c.held, c.recvSeq)
+			c.recvSeq++
+			c.gapSince = time.Time{}
+			if len(c.held) > 0 {
+				c.gapSince = time.Now()
+			}
+			c.pending = data
+			continue
+		}
+
+		deadline := time.Time{}
+		if len(c.held) > 0 {
+			deadline = c.gapSince.Add(udpGapTimeout)
+			if !time.Now().Before(deadline) {
+				c.skipGap()
+				continue
+			}
+		}
+		c.conn.SetReadDeadline(deadline)
+
+		n, err := c.conn.Read(c.packet)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() && len(c.held) > 0 {
+				continue
+			}
+			return 0, err
+		}
+		c.receive(c.packet[:n])
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// receive handles a datagram from the peer.
+func (c *udpConn) receive(datagram []byte) {
+	if len(datagram) < udpHeaderSize {
+		return
+	}
+	seq := binary.BigEndian.Uint16(datagram[1:udpHeaderSize])
+
+	switch datagram[0] {
+	case udpAck:
+		c.mutex.Lock()
+		delete(c.unacked, seq)
+		c.mutex.Unlock()
+	case udpData:
+		c.conn.Write(udpHeader(udpAck, seq))
+
+		ahead := int16(seq - c.recvSeq)
+		if ahead < -udpWindow || ahead >= udpWindow {
+			c.recvSeq = seq
+			c.held = make(map[uint16][]byte)
+			ahead = 0
+		}
+		if ahead < 0 {
+			return
+		}
+		if _, ok := c.held[seq]; !ok {
+			c.held[seq] = append([]byte(nil), datagram[udpHeaderSize:]...)
+		}
+		if ahead > 0 && c.gapSince.IsZero() {
+			c.gapSince = time.Now()
+		}
+	}
+}
+
+// skipGap gives up the missing datagrams before the earliest one held.
+func (c *udpConn) skipGap() {
+	first, found := uint16(0), false
+	for seq := range c.held {
+		if !found || seq-c.recvSeq < first-c.recvSeq {
+			first, found = seq, true
+		}
+	}
+	c.recvSeq = first
+	c.gapSince = time.Time{}
+}
+
+func (c *udpConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + udpPacketSize - udpHeaderSize
+		if end > len(p) {
+			end = len(p)
+		}
+
+		c.mutex.Lock()
+		seq := c.sendSeq
+		c.sendSeq++
+		datagram := append(udpHeader(udpData, seq), p[written:end]...)
+		c.unacked[seq] = &udpDatagram{data: datagram, sent: time.Now()}
+		c.mutex.Unlock()
+
+		if _, err := c.conn.Write(datagram); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// retransmit sends the datagrams which were not acked in time again, until
+// the connection is closed.
+func (c *udpConn) retransmit() {
+	ticker := time.NewTicker(udpRetransmit / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		c.mutex.Lock()
+		for seq, d := range c.unacked {
+			if time.Since(d.sent) < udpRetransmit {
+				continue
+			}
+			if d.tries >= udpRetries {
+				delete(c.unacked, seq)
+				continue
+			}
+			d.tries++
+			d.sent = time.Now()
+			c.conn.Write(d.data)
+		}
+		c.mutex.Unlock()
+	}
+}
+
+func (c *udpConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
+
+// udpHeader returns the header of a datagram of kind with sequence number seq.
+func udpHeader(kind byte, seq uint16) []byte {
+	header := make([]byte, udpHeaderSize, udpPacketSize)
+	header[0] = kind
+	binary.BigEndian.PutUint16(header[1:], seq)
+	return header
+}
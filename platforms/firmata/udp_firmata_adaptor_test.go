@@ -0,0 +1,155 @@
+package firmata
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/gobottest"
+)
+
+var _ gobot.Adaptor = (*UDPAdaptor)(nil)
+
+func TestFirmataUDPAdaptor(t *testing.T) {
+	a := NewUDPAdaptor("localhost:4567", WithHandshakeTimeout(0))
+	gobottest.Assert(t, a.Name(), "UDPFirmata")
+	gobottest.Assert(t, a.Port(), "localhost:4567")
+	gobottest.Assert(t, a.timeouts.Handshake, time.Duration(0))
+}
+
+func TestFirmataUDPAdaptorConnectError(t *testing.T) {
+	a := NewUDPAdaptor("localhost:notaport")
+	gobottest.Refute(t, a.Connect(), nil)
+}
+
+// udpPeer is the board side of a UDP transport test.
+type udpPeer struct {
+	conn net.PacketConn
+	addr net.Addr
+}
+
+// read returns the next datagram from the adaptor.
+func (p *udpPeer) read(t *testing.T) []byte {
+	buf := make([]byte, udpPacketSize)
+	p.conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := p.conn.ReadFrom(buf)
+	gobottest.Assert(t, err, nil)
+	p.addr = addr
+	return buf[:n]
+}
+
+func (p *udpPeer) send(kind byte, seq uint16, data ...byte) {
+	p.conn.WriteTo(append(udpHeader(kind, seq), data...), p.addr)
+}
+
+func newUDPPeer(t *testing.T) (*udpPeer, io.ReadWriteCloser) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	gobottest.Assert(t, err, nil)
+	conn, err := DialUDP(server.LocalAddr().String())
+	gobottest.Assert(t, err, nil)
+	return &udpPeer{conn: server}, conn
+}
+
+// readStream reads n bytes from conn in small pieces.
+func readStream(t *testing.T, conn io.Reader, n int) []byte {
+	var read []byte
+	buf := make([]byte, 3)
+	for len(read) < n {
+		m, err := conn.Read(buf)
+		gobottest.Assert(t, err, nil)
+		read = append(read, buf[:m]...)
+	}
+	return read
+}
+
+func TestDialUDP(t *testing.T) {
+	peer, conn := newUDPPeer(t)
+	defer peer.conn.Close()
+	defer conn.Close()
+
+	n, err := conn.Write([]byte{0xF9})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 1)
+	gobottest.Assert(t, peer.read(t), []byte{udpData, 0, 0, 0xF9})
+	peer.send(udpAck, 0)
+
+	// a message split across datagrams reads as one stream, and every data
+	// datagram is acked
+	peer.send(udpData, 0, 0xF9, 0x02)
+	peer.send(udpData, 1, 0x05, 0xE0)
+	gobottest.Assert(t, readStream(t, conn, 4), []byte{0xF9, 0x02, 0x05, 0xE0})
+	gobottest.Assert(t, peer.read(t), []byte{udpAck, 0, 0})
+	gobottest.Assert(t, peer.read(t), []byte{udpAck, 0, 1})
+}
+
+func TestUDPReorder(t *testing.T) {
+	peer, conn := newUDPPeer(t)
+	defer peer.conn.Close()
+	defer conn.Close()
+	conn.Write([]byte{0xF9})
+	peer.read(t)
+	peer.send(udpAck, 0)
+
+	peer.send(udpData, 1, 0x02)
+	peer.send(udpData, 0, 0x01)
+	// a duplicate is dropped
+	peer.send(udpData, 1, 0x02)
+	peer.send(udpData, 2, 0x03)
+	gobottest.Assert(t, readStream(t, conn, 3), []byte{0x01, 0x02, 0x03})
+}
+
+func TestUDPDrop(t *testing.T) {
+	peer, conn := newUDPPeer(t)
+	defer peer.conn.Close()
+	defer conn.Close()
+	conn.Write([]byte{0xF9})
+	peer.read(t)
+	peer.send(udpAck, 0)
+
+	// datagram 0 never arrives, so the next ones are delivered once the
+	// sender would have given up on it
+	start := time.Now()
+	peer.send(udpData, 1, 0x02)
+	peer.send(udpData, 2, 0x03)
+	gobottest.Assert(t, readStream(t, conn, 2), []byte{0x02, 0x03})
+	gobottest.Assert(t, time.Since(start) >= udpGapTimeout, true)
+}
+
+func TestUDPRetransmit(t *testing.T) {
+	peer, conn := newUDPPeer(t)
+	defer peer.conn.Close()
+	defer conn.Close()
+	// acks are handled while reading, as the client does
+	go io.Copy(ioutil.Discard, conn)
+
+	conn.Write([]byte{0xF9})
+	gobottest.Assert(t, peer.read(t), []byte{udpData, 0, 0, 0xF9})
+	// not acked, so it is sent again
+	gobottest.Assert(t, peer.read(t), []byte{udpData, 0, 0, 0xF9})
+	peer.send(udpAck, 0)
+	time.Sleep(udpRetransmit)
+
+	conn.Write([]byte{0xE0})
+	gobottest.Assert(t, peer.read(t), []byte{udpData, 0, 1, 0xE0})
+	peer.send(udpAck, 1)
+
+	peer.conn.SetReadDeadline(time.Now().Add(2 * udpRetransmit))
+	_, _, err := peer.conn.ReadFrom(make([]byte, udpPacketSize))
+	gobottest.Refute(t, err, nil)
+}
+
+func TestUDPWriteSplit(t *testing.T) {
+	peer, conn := newUDPPeer(t)
+	defer peer.conn.Close()
+	defer conn.Close()
+
+	data := make([]byte, udpPacketSize)
+	n, err := conn.Write(data)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, udpPacketSize)
+	gobottest.Assert(t, len(peer.read(t)), udpPacketSize)
+	gobottest.Assert(t, peer.read(t), []byte{udpData, 0, 1, 0, 0, 0})
+}